@@ -3,9 +3,15 @@
 package tailsamplingprocessor // import "github.com/open-telemetry/opentelemetry-collector-contrib/processor/tailsamplingprocessor"
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
 	"time"
 
 	//"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
 )
 
 type Config struct {
@@ -16,6 +22,20 @@ type Config struct {
 	// BufferSize 是在触发采样决策前，内存中缓存的追踪数量。
 	// 对应 Python TracePicker 的 bufferSize
 	BufferSize uint64 `mapstructure:"buffer_size"`
+
+	// MaxBufferedSpans 是缓冲区中 span 总数的上限，达到后立即触发采样决策。
+	// 扇出很大的追踪会占用远多于普通追踪的内存，该上限使内存占用可预测。
+	// 为 0 时不限制。
+	MaxBufferedSpans uint64 `mapstructure:"max_buffered_spans"`
+
+	// MaxBufferedBytes 是缓冲区中追踪 (OTLP proto 编码) 总字节数的上限，
+	// 达到后立即触发采样决策。为 0 时不限制。
+	MaxBufferedBytes uint64 `mapstructure:"max_buffered_bytes"`
+
+	// PayloadStore 配置缓冲区中正常追踪完整数据的存放。
+	// 优化器只需要每条追踪的类型、异常标记和延迟特征，缓冲区在内存中只保留这些紧凑记录，
+	// 完整数据超过内存上限后溢出到磁盘，只有被选中的追踪会被读回导出。
+	PayloadStore PayloadStoreCfg `mapstructure:"payload_store"`
 	
 	// PoolHeight 是用于计算延迟均值/标准差的历史数据池大小。
 	// 对应 Python TracePicker 的 poolHeight
@@ -25,10 +45,496 @@ type Config struct {
 	// 即使初期用随机采样替代，也保留此配置项以便后续扩展。
 	// 对应 Python TracePicker 的 combCount
 	CombinationCount int `mapstructure:"combination_count"`
+
+	// Sampler 选择正常追踪的采样方式: ga (遗传算法，默认)、cluster
+	// (每个类型内以配额为 k 做 k-medoids 聚类，每个簇保留一个代表)
+	// 或 streaming (不等待缓冲区填满，每个类型一个加权蓄水池，见 Streaming)。
+	// 缓冲区很大时 cluster 比遗传算法更快且结果更稳定。
+	// streaming 不构建延迟矩阵，因此不提取延迟特征，latency_features 和 objective 不生效。
+	Sampler string `mapstructure:"sampler"`
+
+	// Streaming 配置 streaming 采样方式。
+	Streaming StreamingCfg `mapstructure:"streaming"`
+
+	// Optimizer 限制 ga 采样方式中遗传算法每个批次的代数和时间。
+	Optimizer OptimizerCfg `mapstructure:"optimizer"`
+
+	// CandidateStrategy 是为每个类型生成候选子集的策略:
+	// random (均匀随机，默认)、stratified (按总延迟分位数分层)、
+	// latin_hypercube (在标签维度上做拉丁超立方采样)、kmeans_medoids (k-means 簇中心)。
+	// 除 random 外的策略保证每个候选子集包含尾部延迟的追踪。
+	CandidateStrategy string `mapstructure:"candidate_strategy"`
 	
 	// DecisionWait 仍然有用，可以被 groupbytraceprocessor 使用，
     // 或者作为我们内部判断追踪超时的依据。
 	DecisionWait time.Duration `mapstructure:"decision_wait"`
+
+	// AbnormalSigma 是异常检测阈值 mu + k*std 中的 k。追踪关键路径的长度超过关键路径上各 span 阈值
+	// (按 span 在关键路径上的占比) 之和时判为异常，并行扇出的子 span 不会抬高真实耗时。
+	// 对应 Python TracePicker 中固定的 5
+	AbnormalSigma float64 `mapstructure:"abnormal_sigma"`
+
+	// QualityBaselineSamples 是评估采样质量时随机采样基线的 Monte-Carlo 轮数。
+	// 每个批次结束后会计算最终采样集合与随机采样的一致性误差并记录为指标。
+	// 每个批次都要额外评估这么多组随机采样，默认为 0 (关闭质量评估)，需要时再开启，例如 10。
+	QualityBaselineSamples int `mapstructure:"quality_baseline_samples"`
+
+	// LabelNormalization 配置 span 标签的归一化规则，用于控制延迟矩阵的标签基数。
+	LabelNormalization LabelNormalizationCfg `mapstructure:"label_normalization"`
+
+	// LatencyFeatures 配置延迟矩阵中每个标签的特征列。
+	LatencyFeatures LatencyFeaturesCfg `mapstructure:"latency_features"`
+
+	// Objective 配置遗传算法最小化、质量评估使用的一致性目标。
+	Objective ObjectiveCfg `mapstructure:"objective"`
+
+	// NovelTypes 配置新类型追踪的识别与保留。
+	NovelTypes NovelTypesCfg `mapstructure:"novel_types"`
+
+	// ErrorClasses 配置异常追踪按错误类别分组采样。
+	ErrorClasses ErrorClassesCfg `mapstructure:"error_classes"`
+
+	// StateSync 配置多个 collector 副本之间的采样状态共享。
+	StateSync StateSyncCfg `mapstructure:"state_sync"`
+
+	// RuntimeControl 配置运行时修改采样参数的入口。
+	RuntimeControl RuntimeControlCfg `mapstructure:"runtime_control"`
+
+	// WriteTraceState 在保留的追踪的每个 span 的 tracestate 中写入 OpenTelemetry
+	// 概率采样阈值 (ot=th:...)，使后端可以按 1/p 还原请求数。
+	// 优化器选中的追踪 p = 配额/该类型的追踪数，直接保留的追踪 p = 1，与上游已有的阈值取较大者。
+	// 默认关闭。
+	WriteTraceState bool `mapstructure:"write_tracestate"`
+
+	// RedMetrics 配置采样决策之前的 RED 指标。
+	RedMetrics RedMetricsCfg `mapstructure:"red_metrics"`
+
+	// Decisions 配置进程内共享的采样决策存储。
+	Decisions DecisionsCfg `mapstructure:"decisions"`
+
+	// Logs 配置日志处理器，仅在 logs 流水线中使用本处理器时生效。
+	Logs LogsCfg `mapstructure:"logs"`
+
+	// Tenancy 配置按资源属性划分租户。
+	Tenancy TenancyCfg `mapstructure:"tenancy"`
+
+	// IncompleteTraces 配置不完整追踪 (根 span 缺失、多个根或有父 span 缺失的孤立子树) 的处理方式。
+	IncompleteTraces IncompleteTracesCfg `mapstructure:"incomplete_traces"`
+}
+
+// LabelNormalizationCfg 配置 span 标签 ("service:spanName") 的归一化。
+// 例如 HTTP 服务把 ID 放进 span 名称 ("GET /user/123") 时，
+// 不做归一化会产生成千上万个标签。
+type LabelNormalizationCfg struct {
+	// NameAttributes 按顺序查找的 span 属性，第一个存在的属性值代替 span 名称，
+	// 例如 ["http.route"]。
+	NameAttributes []string `mapstructure:"name_attributes"`
+	// Rules 是按顺序匹配的正则改写规则，只应用第一条匹配的规则。
+	Rules []LabelRuleCfg `mapstructure:"rules"`
+	// CollapseNumericSegments 将以 "/" 分隔的纯数字段替换为 "{num}"。
+	CollapseNumericSegments bool `mapstructure:"collapse_numeric_segments"`
+	// CollapseUUIDSegments 将以 "/" 分隔的 UUID 段替换为 "{uuid}"。
+	CollapseUUIDSegments bool `mapstructure:"collapse_uuid_segments"`
+	// MaxLabels 是不同标签数的硬上限，超过后的新标签归入 "other"。0 表示不限制。
+	MaxLabels int `mapstructure:"max_labels"`
+}
+
+// LabelRuleCfg 是一条 span 名称改写规则。
+type LabelRuleCfg struct {
+	// Pattern 是匹配 span 名称的正则表达式。
+	Pattern string `mapstructure:"pattern"`
+	// Template 替换名称中匹配 Pattern 的部分，可以用 $1、${name} 引用捕获组。
+	// 未锚定的 Pattern 只替换匹配的子串，例如 `/users/\d+` → `/users/{id}` 将 "GET /users/42/orders"
+	// 改写为 "GET /users/{id}/orders"。
+	Template string `mapstructure:"template"`
+}
+
+// normalizerConfig 将配置转换为 tracepicker.NormalizerConfig。
+func (cfg LabelNormalizationCfg) normalizerConfig() (tracepicker.NormalizerConfig, error) {
+	rules := make([]tracepicker.LabelRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return tracepicker.NormalizerConfig{}, fmt.Errorf("invalid label_normalization pattern %q: %w", rule.Pattern, err)
+		}
+		rules = append(rules, tracepicker.LabelRule{Pattern: pattern, Template: rule.Template})
+	}
+
+	return tracepicker.NormalizerConfig{
+		NameAttributes:          cfg.NameAttributes,
+		Rules:                   rules,
+		CollapseNumericSegments: cfg.CollapseNumericSegments,
+		CollapseUUIDSegments:    cfg.CollapseUUIDSegments,
+		MaxLabels:               cfg.MaxLabels,
+	}, nil
+}
+
+// LatencyFeaturesCfg 配置延迟矩阵中每个标签的特征。
+// 同一追踪中同一标签出现多次时 (例如循环中的 memcached 调用)，
+// 可以选择聚合方式，也可以为每个标签生成多个特征列。
+type LatencyFeaturesCfg struct {
+	// Features 是每个标签的特征列，可选值:
+	// last (最后一个 span 的耗时，默认)、sum、max、count、self_time (扣除子 span 后的独占耗时)、
+	// critical_path (在追踪关键路径上的耗时，并行扇出中只计入决定结束时刻的分支)。
+	Features []string `mapstructure:"features"`
+	// TraceCriticalPath 在每条追踪的特征末尾追加关键路径总长度一列。
+	TraceCriticalPath bool `mapstructure:"trace_critical_path"`
+	// Resolution 是耗时精度，"ms" (默认) 或 "us"。毫秒精度下亚毫秒的 span 记为 0。
+	Resolution string `mapstructure:"resolution"`
+	// MaxLabels 是每个租户延迟矩阵中标签列数的上限。标签的列下标一经分配不再回收，
+	// 达到上限后新出现的标签不生成特征列，但仍参与编码和异常判定。0 表示不限制。
+	MaxLabels int `mapstructure:"max_labels"`
+}
+
+// featureSpec 将配置转换为 tracepicker.FeatureSpec。
+func (cfg LatencyFeaturesCfg) featureSpec() (tracepicker.FeatureSpec, error) {
+	spec := tracepicker.DefaultFeatureSpec()
+	if len(cfg.Features) > 0 {
+		spec.Features = make([]tracepicker.LatencyFeature, len(cfg.Features))
+		for i, feature := range cfg.Features {
+			spec.Features[i] = tracepicker.LatencyFeature(feature)
+		}
+	}
+
+	spec.TraceCriticalPath = cfg.TraceCriticalPath
+
+	switch cfg.Resolution {
+	case "", "ms":
+		spec.Unit = time.Millisecond
+	case "us":
+		spec.Unit = time.Microsecond
+	default:
+		return spec, fmt.Errorf("invalid latency_features.resolution %q, must be \"ms\" or \"us\"", cfg.Resolution)
+	}
+	return spec, spec.Validate()
+}
+
+// ObjectiveCfg 配置一致性目标：采样后每个特征列的分布与批次原始分布之间的距离之和。
+type ObjectiveCfg struct {
+	// Distance 是 percentile_rmse (比较若干百分位点，默认)、wasserstein (Wasserstein-1 距离)
+	// 或 ks (Kolmogorov-Smirnov 统计量)。
+	Distance string `mapstructure:"distance"`
+	// Percentiles 是 percentile_rmse 比较的百分位点。
+	Percentiles []float64 `mapstructure:"percentiles"`
+	// PercentileWeights 与 Percentiles 一一对应，为空时等权。
+	// 例如降低 p0 和 p100 (各自只由一条追踪决定) 的权重。
+	PercentileWeights []float64 `mapstructure:"percentile_weights"`
+	// LabelWeights 按归一化后的标签 ("service:spanName") 设置权重，未列出的标签权重为 1。
+	// 例如提高前端根 span 的权重。
+	LabelWeights map[string]float64 `mapstructure:"label_weights"`
+	// PresenceWeight 是出现频率项的权重。追踪中缺失的标签不参与分布的比较，
+	// 而是比较采样集合与原始数据中该标签的出现比例，为 0 时不比较出现比例。
+	PresenceWeight float64 `mapstructure:"presence_weight"`
+	// CumulativeWeight 是跨批次累积项的权重，为 0 时 (默认) 不启用。
+	// 累积项按特征列保存至今所有数据和所有采样的直方图，比较候选采样加入后两者的百分位数，
+	// 使连续的批次互相修正偏差，例如每个批次都低估 p99 时整体采样结果仍与原始分布一致。
+	CumulativeWeight float64 `mapstructure:"cumulative_weight"`
+	// CumulativeHalfLife 是累积数据权重减半的时间，为 0 时不衰减。
+	CumulativeHalfLife time.Duration `mapstructure:"cumulative_half_life"`
+}
+
+// objective 将配置转换为 tracepicker.Objective，不含每个批次的列权重。
+func (cfg ObjectiveCfg) objective() (tracepicker.Objective, error) {
+	objective := tracepicker.Objective{
+		Distance:          tracepicker.Distance(cfg.Distance),
+		Percentiles:       cfg.Percentiles,
+		PercentileWeights: cfg.PercentileWeights,
+		PresenceWeight:    cfg.PresenceWeight,
+		CumulativeWeight:  cfg.CumulativeWeight,
+	}
+	if err := objective.Validate(); err != nil {
+		return objective, fmt.Errorf("invalid objective: %w", err)
+	}
+	for label, w := range cfg.LabelWeights {
+		if w < 0 {
+			return objective, fmt.Errorf("objective.label_weights[%s] must not be negative", label)
+		}
+	}
+	if cfg.CumulativeHalfLife < 0 {
+		return objective, fmt.Errorf("objective.cumulative_half_life must not be negative")
+	}
+	return objective, nil
+}
+
+// NovelTypesCfg 配置新类型 (从未见过或很少见的 typeID) 追踪的保留。
+// 新类型追踪像异常追踪一样直接保留，不参与配额竞争，也不受 error_classes.max_per_class 限制。
+// 默认关闭，开启后输出的追踪数会增加。
+type NovelTypesCfg struct {
+	// Enabled 开启新类型识别，默认关闭。
+	Enabled bool `mapstructure:"enabled"`
+	// KeepFirst 是每个类型保证保留的前 N 次出现。
+	KeepFirst uint64 `mapstructure:"keep_first"`
+	// RarityThreshold 是罕见类型的出现比例阈值，例如 0.001 表示出现比例低于 0.1% 的类型
+	// 也始终保留。为 0 时只按 KeepFirst 判断。
+	RarityThreshold float64 `mapstructure:"rarity_threshold"`
+	// MaxTypes 是每个租户记录出现次数的类型数上限，超过后淘汰最久没有出现的类型，
+	// 被淘汰的类型再次出现时重新视为新类型。为 0 时使用默认值 10000。
+	MaxTypes int `mapstructure:"max_types"`
+}
+
+// StreamingCfg 配置流式采样。
+// 低流量服务在批处理模式下要等到 buffer_size 条追踪到达才做决策，
+// 流式模式使从接收到导出的延迟不超过 ReleaseInterval。
+type StreamingCfg struct {
+	// ReleaseInterval 是释放蓄水池的周期。
+	ReleaseInterval time.Duration `mapstructure:"release_interval"`
+	// BudgetInterval 是用 AllocateQuota 重新计算每个类型蓄水池容量的周期。
+	// 第一次计算不等待 BudgetInterval，在第一次释放前按已到达的追踪进行。
+	BudgetInterval time.Duration `mapstructure:"budget_interval"`
+}
+
+// PayloadStoreCfg 配置正常追踪完整数据 (payload) 的存放。
+type PayloadStoreCfg struct {
+	// MemoryLimit 是每个缓冲区保存在内存中的 payload (OTLP proto 编码) 总字节数上限，
+	// 超过后的 payload 写入磁盘段文件，批次结束后删除。默认 256 MiB，为 0 时全部保存在内存中。
+	MemoryLimit uint64 `mapstructure:"memory_limit"`
+	// Directory 是段文件所在的目录，为空时使用系统临时目录。
+	Directory string `mapstructure:"directory"`
+}
+
+// OptimizerCfg 配置遗传算法的运行预算。
+// 大批次可能比 decision_wait 跑得更久，小批次收敛后继续迭代则浪费 CPU。
+// 用完时间预算或提前停止时使用至今找到的最优解。
+type OptimizerCfg struct {
+	// MaxGenerations 是最大代数，为 0 时使用优化器内置的代数。
+	MaxGenerations uint `mapstructure:"max_generations"`
+	// TimeBudget 是每个批次从开始采样到优化结束的时间上限，为 0 时使用 decision_wait。
+	// 只在代与代之间检查，实际耗时可能超出一代的时间。
+	TimeBudget time.Duration `mapstructure:"time_budget"`
+	// Patience 是最优适应度连续多少代没有改善时提前停止，为 0 时不提前停止。
+	Patience uint `mapstructure:"patience"`
+	// EliteArchiveSize 是每个类型保存的最近批次最优解的数量，为 0 (默认) 时不保存。
+	// 下一批次该类型的候选子集和初始种群从这些最优解覆盖的延迟层次播种；
+	// 连续 60 个批次没有出现的类型被删除。
+	EliteArchiveSize int `mapstructure:"elite_archive_size"`
+}
+
+// ErrorClassesCfg 配置异常追踪的错误类别签名。
+// 签名由出错 span 的标签、状态码和 Attributes 中的属性值组成，
+// 异常追踪按签名分组，每组最多保留 MaxPerClass 条。
+type ErrorClassesCfg struct {
+	// Enabled 开启错误类别分组。关闭时保留全部异常追踪。
+	Enabled bool `mapstructure:"enabled"`
+	// Attributes 是参与签名的属性，先在 span 属性中查找，再在 exception 事件中查找。
+	Attributes []string `mapstructure:"attributes"`
+	// MaxPerClass 是每个错误类别每批最多保留的异常追踪数，0 表示不限制。
+	// 只作用于批处理采样 (ga、cluster)，streaming 模式下异常追踪到达即导出，不受此限制。
+	MaxPerClass int `mapstructure:"max_per_class"`
+}
+
+// StateSyncCfg 配置副本间的采样状态共享。
+// 开启后，副本会周期性地通过 gRPC 与 Peers 交换可合并的摘要
+// (每个标签的延迟矩统计量、按 DecayHalfLife 衰减的路径采样计数)，
+// 使 HistPool 的异常基线和配额分配基于全局数据。摘要按租户划分，
+// 每个租户只合并其他副本中同名租户的数据。
+type StateSyncCfg struct {
+	// Enabled 开启状态共享，默认关闭。
+	Enabled bool `mapstructure:"enabled"`
+	// ReplicaID 是本副本的唯一标识，为空时使用主机名。
+	ReplicaID string `mapstructure:"replica_id"`
+	// Endpoint 是本地 gRPC 监听地址，例如 "0.0.0.0:4390"。
+	Endpoint string `mapstructure:"endpoint"`
+	// Peers 是其他副本的 gRPC 地址列表。
+	Peers []string `mapstructure:"peers"`
+	// Interval 是与每个副本交换摘要的周期。
+	Interval time.Duration `mapstructure:"interval"`
+	// DecayHalfLife 是远端摘要随年龄衰减的半衰期，失联副本的数据会逐渐淡出。
+	// 本地的路径采样计数也按它衰减后再发布和参与配额分配。为 0 时不衰减。
+	DecayHalfLife time.Duration `mapstructure:"decay_half_life"`
+}
+
+// RuntimeControlCfg 配置运行时修改采样参数的入口，无需重启 collector。
+// 可修改的参数为 sample_rate、buffer_size 和 abnormal_sigma，
+// 修改在批次之间生效，不会丢失已缓存的追踪和 HistPool 历史。
+type RuntimeControlCfg struct {
+	// Endpoint 是 HTTP 控制接口的监听地址，例如 "localhost:4391"。为空时不启动。
+	// GET /config 返回当前参数，PUT 或 POST /config 以 JSON 修改其中的部分参数。
+	Endpoint string `mapstructure:"endpoint"`
+	// File 是被监视的 JSON 参数文件，文件内容变化时自动应用。为空时不监视。
+	File string `mapstructure:"file"`
+	// PollInterval 是检查 File 是否变化的周期。
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+// RedMetricsCfg 配置在采样决策之前为每条追踪记录的 RED 指标
+// (请求数、错误数、根 span 延迟直方图)，按追踪类型和根端点分组。
+// 采样后后端无法再准确计算请求率和错误率，这些指标基于全部追踪。
+// 指标通过处理器自身的遥测 (processor_tail_sampling_red_*) 输出，处理器不直接产生 pmetric
+// 数据，也没有下游 metrics 消费者。需要进入 metrics 流水线时，在 service::telemetry::metrics 中
+// 配置 OTLP periodic reader 将其发送到 collector 自身的 OTLP receiver。
+type RedMetricsCfg struct {
+	// Enabled 开启 RED 指标。
+	Enabled bool `mapstructure:"enabled"`
+	// IncludeTypeID 在指标上附加 trace_type 属性，默认关闭，只按根端点分组。
+	IncludeTypeID bool `mapstructure:"include_type_id"`
+	// MaxTraceTypes 是每个租户 trace_type 属性的取值上限，超过后新出现的类型记为 "other"，
+	// 避免指标基数随追踪类型无限增长。为 0 时不限制。
+	MaxTraceTypes int `mapstructure:"max_trace_types"`
+}
+
+// DecisionsCfg 配置进程内共享的采样决策存储。
+// 追踪处理器把每个批次中每条追踪的决策写入名为 Name 的存储，
+// 使用相同 Name 的日志处理器据此过滤日志。
+type DecisionsCfg struct {
+	// Name 是共享决策存储的名称，多个采样流水线需要相互隔离时使用不同的名称。
+	Name string `mapstructure:"name"`
+	// TTL 是决策的保留时间，应大于日志的 Wait。
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxEntries 是决策存储保留的决策数上限，超过后最早的决策被淘汰。
+	// 只有配置了日志处理器时追踪处理器才记录决策。
+	MaxEntries int `mapstructure:"max_entries"`
+}
+
+// LogsCfg 配置按追踪采样决策过滤日志。
+type LogsCfg struct {
+	// Wait 是带追踪 ID 的日志等待所属追踪采样决策的最长时间。
+	// 追踪需要先填满缓冲区才会做出决策，Wait 应覆盖一次缓冲区填充的时间。
+	// 所有追踪都有决策的日志不等到 Wait，在之后一秒内 (Wait 较短时为 Wait/4) 转发。
+	Wait time.Duration `mapstructure:"wait"`
+	// UnsampledRatio 是所属追踪未被采样 (或等待超时仍无决策) 的日志的保留比例，
+	// 0 表示全部丢弃，1 表示全部保留。同一追踪的日志一起保留或丢弃。
+	UnsampledRatio float64 `mapstructure:"unsampled_ratio"`
+	// MaxPendingRecords 是等待决策的日志记录数上限，超过后最早的日志提前按当前决策处理。
+	// 为 0 时不限制。
+	MaxPendingRecords int `mapstructure:"max_pending_records"`
+}
+
+// TenancyCfg 配置多租户采样。多个应用共用一个 collector 时，
+// 每个租户有独立的缓冲区、延迟历史、类型计数和蓄水池，高流量的应用不会挤占其他应用的配额。
+// 保留的追踪数和质量指标会附加 tenant 属性。
+type TenancyCfg struct {
+	// Attribute 是划分租户的资源属性，例如 service.namespace、deployment.environment
+	// 或 k8s.namespace.name。取追踪中第一个带有该属性的资源的值。为空时不划分租户。
+	Attribute string `mapstructure:"attribute"`
+	// DefaultTenant 是没有该属性的追踪所属的租户。
+	DefaultTenant string `mapstructure:"default_tenant"`
+	// MaxTenants 是租户数 (包括 DefaultTenant) 的上限，超过后新租户的追踪归入 DefaultTenant。0 表示不限制。
+	MaxTenants int `mapstructure:"max_tenants"`
+	// Overrides 按租户名称覆盖采样参数，运行时修改的参数不影响被覆盖的值。
+	Overrides map[string]TenantOverrideCfg `mapstructure:"overrides"`
+}
+
+// TenantOverrideCfg 是一个租户的采样参数覆盖，为 0 的字段使用全局值。
+type TenantOverrideCfg struct {
+	SampleRate float64 `mapstructure:"sample_rate"`
+	BufferSize uint64  `mapstructure:"buffer_size"`
+}
+
+// IncompleteTracesCfg 配置不完整追踪的处理方式。
+// 不完整追踪按森林编码，每种孤立子树的组合是一个独立的类型。
+type IncompleteTracesCfg struct {
+	// Policy 是 sample (与完整追踪一样参与采样，默认)、keep (全部保留)、drop (全部丢弃)
+	// 或 budget (按 SampleRate 单独随机采样，不占用完整追踪的配额)。
+	// 除 sample 外，策略对有错误的不完整追踪同样生效：drop 丢弃它们，budget 只按 SampleRate 保留，
+	// 它们不会作为异常追踪全部保留。不完整追踪也不参与新类型识别。
+	Policy string `mapstructure:"policy"`
+	// SampleRate 是 budget 策略下不完整追踪的采样率。
+	SampleRate float64 `mapstructure:"sample_rate"`
+}
+
+// Validate 检查配置是否有效。
+func (cfg *Config) Validate() error {
+	if err := validateRuntimeParams(cfg.runtimeParams(), *cfg); err != nil {
+		return err
+	}
+	switch cfg.Sampler {
+	case samplerGA, samplerCluster:
+	case samplerStreaming:
+		if cfg.Streaming.ReleaseInterval <= 0 || cfg.Streaming.BudgetInterval <= 0 {
+			return errors.New("streaming.release_interval and streaming.budget_interval must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid sampler %q, must be %q, %q or %q", cfg.Sampler, samplerGA, samplerCluster, samplerStreaming)
+	}
+	if err := tracepicker.CandidateStrategy(cfg.CandidateStrategy).Validate(); err != nil {
+		return err
+	}
+	if cfg.LabelNormalization.MaxLabels < 0 {
+		return errors.New("label_normalization.max_labels must not be negative")
+	}
+	if _, err := cfg.LabelNormalization.normalizerConfig(); err != nil {
+		return err
+	}
+	if cfg.LatencyFeatures.MaxLabels < 0 {
+		return errors.New("latency_features.max_labels must not be negative")
+	}
+	if _, err := cfg.LatencyFeatures.featureSpec(); err != nil {
+		return err
+	}
+	if dir := cfg.PayloadStore.Directory; dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("payload_store.directory %q is not a directory", dir)
+		}
+	}
+	if cfg.Optimizer.TimeBudget < 0 {
+		return errors.New("optimizer.time_budget must not be negative")
+	}
+	if cfg.Optimizer.EliteArchiveSize < 0 {
+		return errors.New("optimizer.elite_archive_size must not be negative")
+	}
+	if _, err := cfg.Objective.objective(); err != nil {
+		return err
+	}
+	if cfg.NovelTypes.RarityThreshold < 0 || cfg.NovelTypes.RarityThreshold >= 1 {
+		return errors.New("novel_types.rarity_threshold must be in [0, 1)")
+	}
+	if cfg.NovelTypes.MaxTypes < 0 {
+		return errors.New("novel_types.max_types must not be negative")
+	}
+	if cfg.RedMetrics.MaxTraceTypes < 0 {
+		return errors.New("red_metrics.max_trace_types must not be negative")
+	}
+	if cfg.ErrorClasses.MaxPerClass < 0 {
+		return errors.New("error_classes.max_per_class must not be negative")
+	}
+	if cfg.RuntimeControl.File != "" && cfg.RuntimeControl.PollInterval <= 0 {
+		return errors.New("runtime_control.poll_interval must be positive when runtime_control.file is set")
+	}
+	if cfg.Decisions.Name == "" {
+		return errors.New("decisions.name must not be empty")
+	}
+	if cfg.Decisions.MaxEntries <= 0 {
+		return errors.New("decisions.max_entries must be positive")
+	}
+	if cfg.Logs.Wait < 0 {
+		return errors.New("logs.wait must not be negative")
+	}
+	if cfg.Logs.UnsampledRatio < 0 || cfg.Logs.UnsampledRatio > 1 {
+		return errors.New("logs.unsampled_ratio must be in [0, 1]")
+	}
+	if cfg.Logs.MaxPendingRecords < 0 {
+		return errors.New("logs.max_pending_records must not be negative")
+	}
+	if cfg.Tenancy.Attribute != "" && cfg.Tenancy.DefaultTenant == "" {
+		return errors.New("tenancy.default_tenant must not be empty when tenancy.attribute is set")
+	}
+	if cfg.Tenancy.MaxTenants < 0 {
+		return errors.New("tenancy.max_tenants must not be negative")
+	}
+	for name, override := range cfg.Tenancy.Overrides {
+		if override.SampleRate < 0 || override.SampleRate > 1 {
+			return fmt.Errorf("tenancy.overrides[%s].sample_rate must be in [0, 1]", name)
+		}
+	}
+	switch cfg.IncompleteTraces.Policy {
+	case incompleteSample, incompleteKeep, incompleteDrop:
+	case incompleteBudget:
+		if cfg.IncompleteTraces.SampleRate < 0 || cfg.IncompleteTraces.SampleRate > 1 {
+			return errors.New("incomplete_traces.sample_rate must be in [0, 1]")
+		}
+	default:
+		return fmt.Errorf("invalid incomplete_traces.policy %q, must be %q, %q, %q or %q",
+			cfg.IncompleteTraces.Policy, incompleteSample, incompleteKeep, incompleteDrop, incompleteBudget)
+	}
+	if cfg.StateSync.Enabled {
+		if cfg.StateSync.Endpoint == "" {
+			return errors.New("state_sync.endpoint must be set when state_sync is enabled")
+		}
+		if cfg.StateSync.Interval <= 0 {
+			return errors.New("state_sync.interval must be positive")
+		}
+	}
+	return nil
 }
 
 
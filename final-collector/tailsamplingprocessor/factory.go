@@ -10,6 +10,8 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/processor"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
 )
 
 // NewFactory returns a new factory for the Tail Sampling processor.
@@ -17,16 +19,97 @@ func NewFactory() processor.Factory {
 	return processor.NewFactory(
 		Type,
 		createDefaultConfig,
-		processor.WithTraces(createTracesProcessor, TracesStability))
+		processor.WithTraces(createTracesProcessor, TracesStability),
+		processor.WithLogs(createLogsProcessor, LogsStability))
 }
 
 func createDefaultConfig() component.Config {
 	return &Config{
-		SampleRate:       0.1,  // 默认采样率 10%
-		BufferSize:       4000, // 默认缓冲区大小 4000
-		PoolHeight:       1000, // 默认历史池大小 1000
-		CombinationCount: 100,  // 默认组合数 100
-		DecisionWait:     30 * time.Second,
+		SampleRate:        0.1,  // 默认采样率 10%
+		BufferSize:        4000, // 默认缓冲区大小 4000
+		PoolHeight:        1000, // 默认历史池大小 1000
+		CombinationCount:  100,  // 默认组合数 100
+		Sampler:           samplerGA,
+		CandidateStrategy: string(tracepicker.CandidateRandom),
+		DecisionWait:      30 * time.Second,
+		AbnormalSigma:     tracepicker.DefaultAbnormalSigma,
+
+		Streaming: StreamingCfg{
+			ReleaseInterval: 5 * time.Second,
+			BudgetInterval:  time.Minute,
+		},
+
+		PayloadStore: PayloadStoreCfg{
+			MemoryLimit: 256 << 20, // 每个缓冲区 256 MiB
+		},
+
+		Optimizer: OptimizerCfg{
+			Patience: 3,
+		},
+
+		LabelNormalization: LabelNormalizationCfg{
+			CollapseNumericSegments: true,
+			CollapseUUIDSegments:    true,
+			MaxLabels:               1000,
+		},
+
+		LatencyFeatures: LatencyFeaturesCfg{
+			Features:   []string{"last"},
+			Resolution: "ms",
+			MaxLabels:  2000,
+		},
+
+		Objective: ObjectiveCfg{
+			Distance:           string(tracepicker.DistancePercentileRMSE),
+			Percentiles:        append([]float64(nil), tracepicker.DefaultPercentiles...),
+			PresenceWeight:     tracepicker.DefaultPresenceWeight,
+			CumulativeHalfLife: time.Hour,
+		},
+
+		NovelTypes: NovelTypesCfg{
+			KeepFirst: 3,
+		},
+
+		ErrorClasses: ErrorClassesCfg{
+			Attributes:  []string{"http.status_code", "rpc.grpc.status_code", "exception.type"},
+			MaxPerClass: 50,
+		},
+
+		StateSync: StateSyncCfg{
+			Endpoint:      "0.0.0.0:4390",
+			Interval:      10 * time.Second,
+			DecayHalfLife: 5 * time.Minute,
+		},
+
+		RuntimeControl: RuntimeControlCfg{
+			PollInterval: 5 * time.Second,
+		},
+
+		RedMetrics: RedMetricsCfg{
+			Enabled:       true,
+			MaxTraceTypes: 100,
+		},
+
+		Decisions: DecisionsCfg{
+			Name:       "default",
+			TTL:        5 * time.Minute,
+			MaxEntries: 1000000,
+		},
+
+		Logs: LogsCfg{
+			Wait:              time.Minute,
+			MaxPendingRecords: 100000,
+		},
+
+		Tenancy: TenancyCfg{
+			DefaultTenant: "default",
+			MaxTenants:    100,
+		},
+
+		IncompleteTraces: IncompleteTracesCfg{
+			Policy:     incompleteSample,
+			SampleRate: 0.1,
+		},
 	}
 }
 
@@ -43,3 +126,12 @@ func createTracesProcessor(
 	// }
 	return newTracesProcessor(ctx, params, nextConsumer, *tCfg)
 }
+
+func createLogsProcessor(
+	_ context.Context,
+	params processor.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Logs,
+) (processor.Logs, error) {
+	return newLogsProcessor(params, nextConsumer, *cfg.(*Config))
+}
@@ -0,0 +1,80 @@
+// file: processor/tailsamplingprocessor/incomplete.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"math"
+	"math/rand"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// 不完整追踪的处理策略，见 IncompleteTracesCfg.Policy。
+const (
+	incompleteSample = "sample"
+	incompleteKeep   = "keep"
+	incompleteDrop   = "drop"
+	incompleteBudget = "budget"
+)
+
+// handleIncomplete 按 incomplete_traces.policy 处理一条不完整追踪。
+// 返回 false 表示追踪应以 IsIncomplete 进入缓冲区，在批次中单独采样 (批处理模式的 budget 策略)。
+func (tsp *tailSamplingSpanProcessor) handleIncomplete(t *tenant, td ptrace.Traces) bool {
+	cfg := tsp.config.IncompleteTraces
+	switch cfg.Policy {
+	case incompleteKeep:
+		tsp.keepNow(t, td, 1, keepReasonIncomplete)
+		return true
+	case incompleteDrop:
+		tsp.recordTraceDecisions([]ptrace.Traces{td}, false)
+		return true
+	case incompleteBudget:
+		if t.reservoir == nil {
+			return false
+		}
+		// 流式模式下没有批次，逐条按采样率随机保留
+		if rand.Float64() < cfg.SampleRate {
+			tsp.keepNow(t, td, cfg.SampleRate, keepReasonIncomplete)
+		} else {
+			tsp.recordTraceDecisions([]ptrace.Traces{td}, false)
+		}
+		return true
+	}
+	return false
+}
+
+// keepNow 立即导出一条追踪，p 是写入 tracestate 的采样概率。
+func (tsp *tailSamplingSpanProcessor) keepNow(t *tenant, td ptrace.Traces, p float64, reason string) {
+	tsp.markSampled(td, p)
+	tsp.recordKept(t, reason, 1)
+	tsp.exportTraces([]ptrace.Traces{td})
+	tsp.recordTraceDecisions([]ptrace.Traces{td}, true)
+}
+
+// sampleIncomplete 从批次的不完整追踪中随机保留 incomplete_traces.sample_rate 比例，
+// 不占用完整追踪的配额。
+func (tsp *tailSamplingSpanProcessor) sampleIncomplete(t *tenant, traces []ptrace.Traces) []ptrace.Traces {
+	if len(traces) == 0 {
+		return nil
+	}
+	n := int(math.Round(float64(len(traces)) * tsp.config.IncompleteTraces.SampleRate))
+	if n == 0 {
+		return nil
+	}
+
+	rand.Shuffle(len(traces), func(i, j int) { traces[i], traces[j] = traces[j], traces[i] })
+	kept := traces[:n]
+	p := float64(n) / float64(len(traces))
+	for _, td := range kept {
+		tsp.markSampled(td, p)
+	}
+	tsp.recordKept(t, keepReasonIncomplete, n)
+	tsp.logger.Info("Sampled incomplete traces",
+		zap.String("tenant", t.name),
+		zap.Int("incomplete_traces", len(traces)),
+		zap.Int("kept", n))
+	return kept
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/decisions"
+)
+
+// incompleteTrace 返回一条父 span 缺失的追踪，hasError 时 span 的状态为 Error。
+func incompleteTrace(id byte, hasError bool) ptrace.Traces {
+	td := testTrace(id, "GET /orphan", 10*time.Millisecond, "")
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	span.SetParentSpanID(pcommon.SpanID{id, 0xff})
+	if hasError {
+		span.Status().SetCode(ptrace.StatusCodeError)
+	}
+	return td
+}
+
+func TestIncompletePolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		sampler    string
+		sampleRate float64
+		hasError   bool
+		// wantState 是立即导出的追踪的 tracestate，为空表示没有立即导出
+		wantState    string
+		wantDecision decisions.Decision
+		wantBuffered bool
+	}{
+		{name: "sample buffers", policy: incompleteSample, sampler: samplerGA, wantDecision: decisions.Pending, wantBuffered: true},
+		{name: "keep", policy: incompleteKeep, sampler: samplerGA, wantState: "ot=th:0", wantDecision: decisions.Sampled},
+		{name: "keep abnormal", policy: incompleteKeep, sampler: samplerGA, hasError: true, wantState: "ot=th:0", wantDecision: decisions.Sampled},
+		{name: "drop", policy: incompleteDrop, sampler: samplerGA, wantDecision: decisions.NotSampled},
+		// drop 和 budget 同样作用于有错误的不完整追踪，它们不会作为异常追踪保留
+		{name: "drop abnormal", policy: incompleteDrop, sampler: samplerGA, hasError: true, wantDecision: decisions.NotSampled},
+		{name: "budget batch buffers", policy: incompleteBudget, sampler: samplerGA, sampleRate: 1, wantDecision: decisions.Pending, wantBuffered: true},
+		{name: "budget streaming keep", policy: incompleteBudget, sampler: samplerStreaming, sampleRate: 1, wantState: "ot=th:0", wantDecision: decisions.Sampled},
+		{name: "budget streaming abnormal dropped", policy: incompleteBudget, sampler: samplerStreaming, sampleRate: 0, hasError: true, wantDecision: decisions.NotSampled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.WriteTraceState = true
+			cfg.Sampler = tt.sampler
+			cfg.IncompleteTraces.Policy = tt.policy
+			cfg.IncompleteTraces.SampleRate = tt.sampleRate
+			subscribeDecisions(t, cfg)
+			sink := new(consumertest.TracesSink)
+			tsp := newTestProcessor(t, cfg, sink)
+
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), incompleteTrace(1, tt.hasError)))
+
+			states := exportedThresholds(sink)
+			if tt.wantState == "" {
+				assert.Empty(t, states)
+			} else {
+				assert.Equal(t, map[byte]string{1: tt.wantState}, states)
+			}
+			assert.Equal(t, tt.wantDecision, tsp.decisionStore.Lookup(pcommon.TraceID{1}))
+
+			tn := tsp.tenantFor(ptrace.NewTraces())
+			if tt.wantBuffered {
+				assert.Equal(t, uint64(1), tn.buffer.Count())
+			} else {
+				assert.Zero(t, tn.buffer.Count())
+			}
+		})
+	}
+}
+
+func TestIncompleteBudgetBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		hasError   bool
+		wantKept   int
+		wantState  string
+	}{
+		{name: "keep all", sampleRate: 1, wantKept: 4, wantState: "ot=th:0"},
+		{name: "keep half", sampleRate: 0.5, wantKept: 2, wantState: "ot=th:8"},
+		{name: "abnormal follow the budget", sampleRate: 0.25, hasError: true, wantKept: 1, wantState: "ot=th:c"},
+		{name: "keep none", sampleRate: 0, wantKept: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.WriteTraceState = true
+			cfg.IncompleteTraces.Policy = incompleteBudget
+			cfg.IncompleteTraces.SampleRate = tt.sampleRate
+			subscribeDecisions(t, cfg)
+			sink := new(consumertest.TracesSink)
+			tsp := newTestProcessor(t, cfg, sink)
+
+			for id := byte(1); id <= 4; id++ {
+				require.NoError(t, tsp.ConsumeTraces(context.Background(), incompleteTrace(id, tt.hasError)))
+			}
+			tn := tsp.tenantFor(ptrace.NewTraces())
+			batch := tn.buffer.SwapAndClear()
+			require.Len(t, batch.Incomplete, 4)
+			assert.Empty(t, batch.Abnormal)
+
+			tsp.runBatchSampling(tn, batch)
+			states := exportedThresholds(sink)
+			assert.Len(t, states, tt.wantKept)
+			for id, state := range states {
+				assert.Equal(t, tt.wantState, state)
+				assert.Equal(t, decisions.Sampled, tsp.decisionStore.Lookup(pcommon.TraceID{id}))
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// file: processor/tailsamplingprocessor/internal/decisions/store.go
+
+package decisions
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Decision 是一条追踪的采样决策。
+type Decision int8
+
+const (
+	// Pending 表示追踪尚未做出决策 (仍在缓冲区中，或决策已过期)。
+	Pending Decision = iota
+	// Sampled 表示追踪被保留。
+	Sampled
+	// NotSampled 表示追踪被丢弃。
+	NotSampled
+)
+
+type entry struct {
+	decision  Decision
+	decidedAt time.Time
+	seq       uint64
+}
+
+// orderEntry 是过期队列中的一项。seq 与 entries 中的不一致时说明该追踪已被重新记录，
+// 这一项已失效。
+type orderEntry struct {
+	id  pcommon.TraceID
+	seq uint64
+}
+
+// Store 记录最近的追踪采样决策，供日志等其他信号按追踪 ID 查询。
+// 决策在 TTL 之后过期，超过 maxEntries 时最早的决策被淘汰。
+type Store struct {
+	mu         sync.RWMutex
+	ttl        time.Duration
+	maxEntries int // 决策数上限，0 表示不限制
+	entries    map[pcommon.TraceID]entry
+	order      []orderEntry // 按决策时间排序，用于过期清理
+	seq        uint64
+	now        func() time.Time
+	readers    atomic.Int32 // 通过 Subscribe 订阅的读者数
+}
+
+// NewStore 是 Store 的构造函数。
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		entries: make(map[pcommon.TraceID]entry),
+		now:     time.Now,
+	}
+}
+
+// Record 记录一组追踪的采样决策。
+func (s *Store) Record(ids []pcommon.TraceID, sampled bool) {
+	decision := NotSampled
+	if sampled {
+		decision = Sampled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.expire(now)
+	for _, id := range ids {
+		// 重新记录的追踪移到队尾，旧位置在过期时跳过，避免它挡住后面的过期项。
+		s.seq++
+		s.order = append(s.order, orderEntry{id: id, seq: s.seq})
+		s.entries[id] = entry{decision: decision, decidedAt: now, seq: s.seq}
+	}
+	s.evict()
+}
+
+// Lookup 返回追踪的采样决策，未知或已过期的追踪返回 Pending。
+func (s *Store) Lookup(id pcommon.TraceID) Decision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[id]
+	if !ok || (s.ttl > 0 && s.now().Sub(e.decidedAt) > s.ttl) {
+		return Pending
+	}
+	return e.decision
+}
+
+// Len 返回当前记录的决策数。
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// SetTTL 修改决策的过期时间。共享同一个 Store 的处理器取最大的 TTL。
+func (s *Store) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ttl > s.ttl {
+		s.ttl = ttl
+	}
+}
+
+// SetMaxEntries 修改决策数上限。共享同一个 Store 的处理器取最大的上限。
+func (s *Store) SetMaxEntries(maxEntries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if maxEntries > s.maxEntries {
+		s.maxEntries = maxEntries
+	}
+}
+
+// HasReaders 返回是否有读者订阅了这个 Store。没有读者时写入方不必记录决策。
+func (s *Store) HasReaders() bool {
+	return s.readers.Load() > 0
+}
+
+// Unsubscribe 取消一次 Subscribe。
+func (s *Store) Unsubscribe() {
+	s.readers.Add(-1)
+}
+
+// evict 按决策时间从早到晚删除决策，直到不超过 maxEntries。调用者必须持有写锁。
+func (s *Store) evict() {
+	if s.maxEntries <= 0 {
+		return
+	}
+	n := 0
+	for len(s.entries) > s.maxEntries && n < len(s.order) {
+		o := s.order[n]
+		if e, ok := s.entries[o.id]; ok && e.seq == o.seq {
+			delete(s.entries, o.id)
+		}
+		n++
+	}
+	s.order = s.order[n:]
+}
+
+// expire 删除超过 TTL 的决策。调用者必须持有写锁。
+func (s *Store) expire(now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	n := 0
+	for _, o := range s.order {
+		e, ok := s.entries[o.id]
+		if !ok || e.seq != o.seq {
+			n++
+			continue
+		}
+		if now.Sub(e.decidedAt) <= s.ttl {
+			break
+		}
+		delete(s.entries, o.id)
+		n++
+	}
+	s.order = s.order[n:]
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Store)
+)
+
+// Shared 返回名为 name 的进程内共享 Store，不存在时创建。
+// 追踪处理器与日志处理器通过相同的名称共享决策。
+func Shared(name string, ttl time.Duration, maxEntries int) *Store {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	s, ok := registry[name]
+	if !ok {
+		s = NewStore(ttl)
+		registry[name] = s
+	}
+	s.SetTTL(ttl)
+	s.SetMaxEntries(maxEntries)
+	return s
+}
+
+// Subscribe 与 Shared 相同，同时把调用方登记为读者，不再读取时调用 Store.Unsubscribe。
+// 写入方只在有读者时记录决策，没有日志处理器时决策不占用内存。
+func Subscribe(name string, ttl time.Duration, maxEntries int) *Store {
+	s := Shared(name, ttl, maxEntries)
+	s.readers.Add(1)
+	return s
+}
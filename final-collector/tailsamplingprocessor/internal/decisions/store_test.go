@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package decisions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestStoreRecordAndLookup(t *testing.T) {
+	s := NewStore(time.Minute)
+	s.Record([]pcommon.TraceID{{1}}, true)
+	s.Record([]pcommon.TraceID{{2}}, false)
+
+	assert.Equal(t, Sampled, s.Lookup(pcommon.TraceID{1}))
+	assert.Equal(t, NotSampled, s.Lookup(pcommon.TraceID{2}))
+	assert.Equal(t, Pending, s.Lookup(pcommon.TraceID{3}))
+}
+
+func TestStoreExpires(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	s.Record([]pcommon.TraceID{{1}}, true)
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, Pending, s.Lookup(pcommon.TraceID{1}))
+
+	s.Record([]pcommon.TraceID{{2}}, true)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStoreRerecordMovesToTail(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	s.Record([]pcommon.TraceID{{1}}, true)
+	s.Record([]pcommon.TraceID{{2}}, true)
+	now = now.Add(50 * time.Second)
+	s.Record([]pcommon.TraceID{{1}}, false)
+
+	// {2} 过期，重新记录的 {1} 不应挡住它。
+	now = now.Add(20 * time.Second)
+	s.Record([]pcommon.TraceID{{3}}, true)
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, NotSampled, s.Lookup(pcommon.TraceID{1}))
+	assert.Equal(t, Pending, s.Lookup(pcommon.TraceID{2}))
+
+	// 反复重新记录同一个追踪时，过期队列不会无限增长。
+	for i := 0; i < 100; i++ {
+		now = now.Add(time.Second)
+		s.Record([]pcommon.TraceID{{1}}, true)
+	}
+	assert.Equal(t, 1, s.Len())
+	assert.LessOrEqual(t, len(s.order), 61)
+}
+
+func TestStoreMaxEntries(t *testing.T) {
+	s := NewStore(time.Minute)
+	s.SetMaxEntries(2)
+	s.Record([]pcommon.TraceID{{1}, {2}}, true)
+	s.Record([]pcommon.TraceID{{1}}, false)
+
+	// 超过上限时淘汰最早的决策，重新记录的 {1} 排在 {2} 之后
+	s.Record([]pcommon.TraceID{{3}}, true)
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, NotSampled, s.Lookup(pcommon.TraceID{1}))
+	assert.Equal(t, Pending, s.Lookup(pcommon.TraceID{2}))
+	assert.Equal(t, Sampled, s.Lookup(pcommon.TraceID{3}))
+	assert.Len(t, s.order, 2)
+}
+
+func TestSharedReturnsSameStore(t *testing.T) {
+	a := Shared("test-shared", time.Minute, 10)
+	b := Shared("test-shared", 2*time.Minute, 5)
+	assert.Same(t, a, b)
+	assert.Equal(t, 2*time.Minute, b.ttl)
+	assert.Equal(t, 10, b.maxEntries)
+}
+
+func TestSubscribeCountsReaders(t *testing.T) {
+	s := Shared("test-subscribe", time.Minute, 10)
+	assert.False(t, s.HasReaders())
+
+	a := Subscribe("test-subscribe", time.Minute, 10)
+	b := Subscribe("test-subscribe", time.Minute, 10)
+	assert.Same(t, s, a)
+	assert.True(t, s.HasReaders())
+
+	a.Unsubscribe()
+	assert.True(t, s.HasReaders())
+	b.Unsubscribe()
+	assert.False(t, s.HasReaders())
+}
@@ -26,11 +26,20 @@ type TelemetryBuilder struct {
 	meter                                               metric.Meter
 	mu                                                  sync.Mutex
 	registrations                                       []metric.Registration
+	ProcessorTailSamplingBatchConsistencyErrorRatio     metric.Float64Gauge
+	ProcessorTailSamplingBatchRandomConsistencyError    metric.Float64Gauge
+	ProcessorTailSamplingBatchSampledConsistencyError   metric.Float64Gauge
 	ProcessorTailSamplingCountSpansSampled              metric.Int64Counter
+	ProcessorTailSamplingCountTracesKept                metric.Int64Counter
 	ProcessorTailSamplingCountTracesSampled             metric.Int64Counter
 	ProcessorTailSamplingEarlyReleasesFromCacheDecision metric.Int64Counter
 	ProcessorTailSamplingGlobalCountTracesSampled       metric.Int64Counter
 	ProcessorTailSamplingNewTraceIDReceived             metric.Int64Counter
+	ProcessorTailSamplingOptimizerDuration              metric.Int64Histogram
+	ProcessorTailSamplingOptimizerGenerations           metric.Int64Histogram
+	ProcessorTailSamplingRedDuration                    metric.Float64Histogram
+	ProcessorTailSamplingRedErrors                      metric.Int64Counter
+	ProcessorTailSamplingRedRequests                    metric.Int64Counter
 	ProcessorTailSamplingSamplingDecisionLatency        metric.Int64Histogram
 	ProcessorTailSamplingSamplingDecisionTimerLatency   metric.Int64Histogram
 	ProcessorTailSamplingSamplingLateSpanAge            metric.Int64Histogram
@@ -69,12 +78,36 @@ func NewTelemetryBuilder(settings component.TelemetrySettings, options ...Teleme
 	}
 	builder.meter = Meter(settings)
 	var err, errs error
+	builder.ProcessorTailSamplingBatchConsistencyErrorRatio, err = builder.meter.Float64Gauge(
+		"otelcol_processor_tail_sampling_batch_consistency_error_ratio",
+		metric.WithDescription("Ratio of the optimizer's consistency error to the random baseline for the last batch, below 1 means the optimizer beat random sampling"),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingBatchRandomConsistencyError, err = builder.meter.Float64Gauge(
+		"otelcol_processor_tail_sampling_batch_random_consistency_error",
+		metric.WithDescription("Expected consistency error of a random sample of the same size for the last batch (Monte-Carlo estimate)"),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingBatchSampledConsistencyError, err = builder.meter.Float64Gauge(
+		"otelcol_processor_tail_sampling_batch_sampled_consistency_error",
+		metric.WithDescription("Consistency error of the traces selected by the optimizer for the last batch"),
+		metric.WithUnit("1"),
+	)
+	errs = errors.Join(errs, err)
 	builder.ProcessorTailSamplingCountSpansSampled, err = builder.meter.Int64Counter(
 		"otelcol_processor_tail_sampling_count_spans_sampled",
 		metric.WithDescription("Count of spans that were sampled or not per sampling policy"),
 		metric.WithUnit("{spans}"),
 	)
 	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingCountTracesKept, err = builder.meter.Int64Counter(
+		"otelcol_processor_tail_sampling_count_traces_kept",
+		metric.WithDescription("Count of traces kept by the sampler, by keep reason (abnormal, novel, optimizer, cluster, reservoir, incomplete, random_fallback) and tenant"),
+		metric.WithUnit("{traces}"),
+	)
+	errs = errors.Join(errs, err)
 	builder.ProcessorTailSamplingCountTracesSampled, err = builder.meter.Int64Counter(
 		"otelcol_processor_tail_sampling_count_traces_sampled",
 		metric.WithDescription("Count of traces that were sampled or not per sampling policy"),
@@ -99,6 +132,39 @@ func NewTelemetryBuilder(settings component.TelemetrySettings, options ...Teleme
 		metric.WithUnit("{traces}"),
 	)
 	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingOptimizerDuration, err = builder.meter.Int64Histogram(
+		"otelcol_processor_tail_sampling_optimizer_duration",
+		metric.WithDescription("Wall-clock time of the genetic algorithm per batch, by stop reason and tenant"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries([]float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}...),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingOptimizerGenerations, err = builder.meter.Int64Histogram(
+		"otelcol_processor_tail_sampling_optimizer_generations",
+		metric.WithDescription("Generations run by the genetic algorithm per batch, by stop reason (generations, deadline, converged, failed) and tenant"),
+		metric.WithUnit("{generations}"),
+		metric.WithExplicitBucketBoundaries([]float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}...),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingRedDuration, err = builder.meter.Float64Histogram(
+		"otelcol_processor_tail_sampling_red_duration",
+		metric.WithDescription("Root span duration of traces received before the sampling decision, by trace type and root endpoint"),
+		metric.WithUnit("ms"),
+		metric.WithExplicitBucketBoundaries([]float64{1, 2, 5, 10, 25, 50, 75, 100, 150, 200, 300, 400, 500, 750, 1000, 2000, 3000, 4000, 5000, 10000, 20000, 30000, 50000}...),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingRedErrors, err = builder.meter.Int64Counter(
+		"otelcol_processor_tail_sampling_red_errors",
+		metric.WithDescription("Count of traces with at least one error span received before the sampling decision, by trace type and root endpoint"),
+		metric.WithUnit("{traces}"),
+	)
+	errs = errors.Join(errs, err)
+	builder.ProcessorTailSamplingRedRequests, err = builder.meter.Int64Counter(
+		"otelcol_processor_tail_sampling_red_requests",
+		metric.WithDescription("Count of traces received before the sampling decision, by trace type and root endpoint"),
+		metric.WithUnit("{traces}"),
+	)
+	errs = errors.Join(errs, err)
 	builder.ProcessorTailSamplingSamplingDecisionLatency, err = builder.meter.Int64Histogram(
 		"otelcol_processor_tail_sampling_sampling_decision_latency",
 		metric.WithDescription("Latency (in microseconds) of a given sampling policy"),
@@ -0,0 +1,23 @@
+// file: processor/tailsamplingprocessor/internal/statesync/codec.go
+
+package statesync
+
+import (
+	"encoding/json"
+)
+
+// jsonCodec 是 gRPC 的 JSON 编解码器。
+// 摘要结构简单，使用 JSON 可以省去 protobuf 代码生成。
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
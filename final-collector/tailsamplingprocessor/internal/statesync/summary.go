@@ -0,0 +1,47 @@
+// file: processor/tailsamplingprocessor/internal/statesync/summary.go
+
+package statesync
+
+import (
+	"math"
+	"time"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+// Summary 是一个副本在某一时刻的可合并采样状态摘要，按租户划分。
+// 多个副本中同一租户的摘要按字段相加即可得到该租户的全局视图。
+type Summary struct {
+	ReplicaID string                 `json:"replica_id"`
+	Timestamp time.Time              `json:"timestamp"`
+	Tenants   map[string]TenantState `json:"tenants"` // key: 租户名称，不分租户时为空字符串
+}
+
+// TenantState 是一个租户的采样状态。
+type TenantState struct {
+	Labels map[string]tracepicker.Moments `json:"labels"` // key: label, value: 延迟矩统计量
+	Paths  map[string]float64             `json:"paths"`  // key: typeID, value: 已采样计数
+}
+
+// decayWeight 根据摘要的年龄计算指数衰减权重，半衰期为 halfLife。
+// halfLife <= 0 表示不衰减。
+func decayWeight(age, halfLife time.Duration) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// mergeInto 将 s 按权重 w 累加到 labels 和 paths 中。
+func (s TenantState) mergeInto(labels map[string]tracepicker.Moments, paths map[string]float64, w float64) {
+	for label, m := range s.Labels {
+		acc := labels[label]
+		acc.Count += m.Count * w
+		acc.Sum += m.Sum * w
+		acc.SumSq += m.SumSq * w
+		labels[label] = acc
+	}
+	for typeID, c := range s.Paths {
+		paths[typeID] += c * w
+	}
+}
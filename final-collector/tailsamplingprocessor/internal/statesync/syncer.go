@@ -0,0 +1,249 @@
+// file: processor/tailsamplingprocessor/internal/statesync/syncer.go
+
+package statesync
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+const exchangeMethod = "/tailsampling.statesync.StateSync/Exchange"
+
+// Settings 是状态同步的运行参数。
+type Settings struct {
+	ReplicaID     string        // 本副本的唯一标识
+	Endpoint      string        // 本地 gRPC 监听地址
+	Peers         []string      // 其他副本的 gRPC 地址
+	Interval      time.Duration // 与每个副本交换摘要的周期
+	DecayHalfLife time.Duration // 远端摘要随年龄衰减的半衰期，<= 0 表示不衰减
+}
+
+// LocalState 提供本副本当前每个租户的采样状态。
+type LocalState interface {
+	TenantStates() map[string]TenantState
+}
+
+// exchanger 是 StateSync gRPC 服务的处理接口。
+type exchanger interface {
+	Exchange(ctx context.Context, in *Summary) (*Summary, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tailsampling.statesync.StateSync",
+	HandlerType: (*exchanger)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Exchange", Handler: exchangeHandler},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func exchangeHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(Summary)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(exchanger).Exchange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: exchangeMethod}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(exchanger).Exchange(ctx, req.(*Summary))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Syncer 周期性地与其他副本交换采样状态摘要，并维护合并后的远端视图。
+// 每个副本只保存每个对端最新的一份摘要，因此重复交换不会重复计数。
+type Syncer struct {
+	settings Settings
+	local    LocalState
+	onUpdate func()
+	logger   *zap.Logger
+
+	mutex  sync.RWMutex
+	remote map[string]Summary // key: replicaID, value: 该副本最新的摘要
+
+	server   *grpc.Server
+	listener net.Listener
+	conns    []*grpc.ClientConn
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSyncer 是 Syncer 的构造函数。
+// onUpdate 在远端视图变化后被调用，可以为 nil。
+func NewSyncer(settings Settings, local LocalState, onUpdate func(), logger *zap.Logger) *Syncer {
+	return &Syncer{
+		settings: settings,
+		local:    local,
+		onUpdate: onUpdate,
+		logger:   logger,
+		remote:   make(map[string]Summary),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start 启动 gRPC 服务端，建立到各个对端的连接，并开始周期性交换。
+func (s *Syncer) Start() error {
+	listener, err := net.Listen("tcp", s.settings.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.settings.Endpoint, err)
+	}
+	s.listener = listener
+
+	s.server = grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	s.server.RegisterService(&serviceDesc, s)
+	go func() {
+		if err := s.server.Serve(listener); err != nil {
+			s.logger.Warn("State sync server stopped", zap.Error(err))
+		}
+	}()
+
+	for _, peer := range s.settings.Peers {
+		conn, err := grpc.NewClient(peer,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})))
+		if err != nil {
+			s.Shutdown()
+			return fmt.Errorf("failed to create client for peer %s: %w", peer, err)
+		}
+		s.conns = append(s.conns, conn)
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+
+	s.logger.Info("State sync started",
+		zap.String("replica_id", s.settings.ReplicaID),
+		zap.String("endpoint", s.Addr()),
+		zap.Strings("peers", s.settings.Peers))
+	return nil
+}
+
+// Addr 返回实际的监听地址。
+func (s *Syncer) Addr() string {
+	if s.listener == nil {
+		return s.settings.Endpoint
+	}
+	return s.listener.Addr().String()
+}
+
+// Shutdown 停止周期性交换并关闭所有连接。
+func (s *Syncer) Shutdown() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+	s.wg.Wait()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	if s.server != nil {
+		s.server.Stop()
+	}
+}
+
+func (s *Syncer) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.SyncOnce(context.Background())
+		}
+	}
+}
+
+// SyncOnce 与所有对端各交换一次摘要。
+// 单个对端失败只记录日志，不影响其他对端。
+func (s *Syncer) SyncOnce(ctx context.Context) {
+	local := s.localSummary()
+
+	for i, conn := range s.conns {
+		callCtx, cancel := context.WithTimeout(ctx, s.settings.Interval)
+		reply := new(Summary)
+		err := conn.Invoke(callCtx, exchangeMethod, &local, reply)
+		cancel()
+		if err != nil {
+			s.logger.Debug("Failed to exchange state with peer",
+				zap.String("peer", s.settings.Peers[i]),
+				zap.Error(err))
+			continue
+		}
+		s.store(*reply)
+	}
+
+	if s.onUpdate != nil {
+		s.onUpdate()
+	}
+}
+
+// Exchange 实现 StateSync 服务：保存对端的摘要并返回本副本的摘要。
+func (s *Syncer) Exchange(_ context.Context, in *Summary) (*Summary, error) {
+	s.store(*in)
+	if s.onUpdate != nil {
+		s.onUpdate()
+	}
+
+	local := s.localSummary()
+	return &local, nil
+}
+
+// Remote 返回所有对端中租户 tenant 的摘要按年龄衰减后的合并结果，不包含本副本的数据。
+func (s *Syncer) Remote(tenant string) (map[string]tracepicker.Moments, map[string]float64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	labels := make(map[string]tracepicker.Moments)
+	paths := make(map[string]float64)
+	now := time.Now()
+	for _, sum := range s.remote {
+		state, ok := sum.Tenants[tenant]
+		if !ok {
+			continue
+		}
+		w := decayWeight(now.Sub(sum.Timestamp), s.settings.DecayHalfLife)
+		state.mergeInto(labels, paths, w)
+	}
+	return labels, paths
+}
+
+// store 保存一个对端的摘要，只保留每个副本最新的一份。
+func (s *Syncer) store(sum Summary) {
+	if sum.ReplicaID == "" || sum.ReplicaID == s.settings.ReplicaID {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if prev, ok := s.remote[sum.ReplicaID]; ok && prev.Timestamp.After(sum.Timestamp) {
+		return
+	}
+	s.remote[sum.ReplicaID] = sum
+}
+
+func (s *Syncer) localSummary() Summary {
+	return Summary{
+		ReplicaID: s.settings.ReplicaID,
+		Timestamp: time.Now(),
+		Tenants:   s.local.TenantStates(),
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package statesync
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+type fakeState map[string]TenantState
+
+func (f fakeState) TenantStates() map[string]TenantState { return f }
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func TestSyncersConvergeAcrossReplicas(t *testing.T) {
+	addrs := []string{freeAddr(t), freeAddr(t), freeAddr(t)}
+	states := []fakeState{
+		{"": {
+			Labels: map[string]tracepicker.Moments{"frontend:GET": {Count: 2, Sum: 20, SumSq: 200}},
+			Paths:  map[string]float64{"a": 5},
+		}},
+		{"": {
+			Labels: map[string]tracepicker.Moments{"frontend:GET": {Count: 1, Sum: 30, SumSq: 900}},
+			Paths:  map[string]float64{"a": 1, "b": 3},
+		}},
+		{"": {
+			Labels: map[string]tracepicker.Moments{"search:Nearby": {Count: 4, Sum: 8, SumSq: 16}},
+			Paths:  map[string]float64{"c": 2},
+		}},
+	}
+
+	syncers := make([]*Syncer, len(addrs))
+	for i, addr := range addrs {
+		var peers []string
+		for j, peer := range addrs {
+			if j != i {
+				peers = append(peers, peer)
+			}
+		}
+		syncers[i] = NewSyncer(Settings{
+			ReplicaID: addr,
+			Endpoint:  addr,
+			Peers:     peers,
+			Interval:  time.Hour,
+		}, states[i], nil, zap.NewNop())
+		require.NoError(t, syncers[i].Start())
+	}
+	defer func() {
+		for _, s := range syncers {
+			s.Shutdown()
+		}
+	}()
+
+	for _, s := range syncers {
+		s.SyncOnce(context.Background())
+	}
+
+	for i, s := range syncers {
+		labels, paths := s.Remote("")
+
+		wantLabels := make(map[string]tracepicker.Moments)
+		wantPaths := make(map[string]float64)
+		for j, state := range states {
+			if j != i {
+				state[""].mergeInto(wantLabels, wantPaths, 1)
+			}
+		}
+
+		for label, want := range wantLabels {
+			assert.InDelta(t, want.Count, labels[label].Count, 1e-6)
+			assert.InDelta(t, want.Sum, labels[label].Sum, 1e-6)
+			assert.InDelta(t, want.SumSq, labels[label].SumSq, 1e-6)
+		}
+		for typeID, want := range wantPaths {
+			assert.InDelta(t, want, paths[typeID], 1e-6)
+		}
+	}
+}
+
+func TestRepeatedExchangeDoesNotDoubleCount(t *testing.T) {
+	s := NewSyncer(Settings{ReplicaID: "self"}, fakeState{}, nil, zap.NewNop())
+
+	sum := Summary{ReplicaID: "peer", Timestamp: time.Now(), Tenants: map[string]TenantState{
+		"": {Paths: map[string]float64{"a": 3}},
+	}}
+	_, err := s.Exchange(context.Background(), &sum)
+	require.NoError(t, err)
+	_, err = s.Exchange(context.Background(), &sum)
+	require.NoError(t, err)
+
+	_, paths := s.Remote("")
+	assert.InDelta(t, 3, paths["a"], 1e-6)
+}
+
+func TestRemoteIsKeyedByTenant(t *testing.T) {
+	s := NewSyncer(Settings{ReplicaID: "self"}, fakeState{}, nil, zap.NewNop())
+
+	sum := Summary{ReplicaID: "peer", Timestamp: time.Now(), Tenants: map[string]TenantState{
+		"shop":    {Labels: map[string]tracepicker.Moments{"frontend:GET": {Count: 2, Sum: 20}}, Paths: map[string]float64{"a": 3}},
+		"billing": {Labels: map[string]tracepicker.Moments{"frontend:GET": {Count: 5, Sum: 500}}, Paths: map[string]float64{"a": 7}},
+	}}
+	_, err := s.Exchange(context.Background(), &sum)
+	require.NoError(t, err)
+
+	labels, paths := s.Remote("shop")
+	assert.InDelta(t, 2, labels["frontend:GET"].Count, 1e-6)
+	assert.InDelta(t, 3, paths["a"], 1e-6)
+
+	labels, paths = s.Remote("other")
+	assert.Empty(t, labels)
+	assert.Empty(t, paths)
+}
+
+func TestDecayWeight(t *testing.T) {
+	assert.InDelta(t, 1, decayWeight(time.Minute, 0), 1e-9)
+	assert.InDelta(t, 0.5, decayWeight(time.Minute, time.Minute), 1e-9)
+	assert.InDelta(t, 0.25, decayWeight(2*time.Minute, time.Minute), 1e-9)
+}
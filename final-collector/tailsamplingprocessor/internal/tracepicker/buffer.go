@@ -4,47 +4,183 @@ package tracepicker
 
 import (
 	"sync"
+
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
 
+// BufferLimits 定义触发批处理的缓冲区上限。
+// 任意一个上限先达到即视为缓冲区已满，值为 0 的上限不生效。
+type BufferLimits struct {
+	Traces uint64 // 追踪数上限
+	Spans  uint64 // span 总数上限
+	Bytes  uint64 // OTLP proto 编码后的总字节数上限
+}
+
 // SharedBuffer 缓存追踪数据，直到达到批处理大小。
-// 它会区分正常和异常追踪。
+// 它会区分正常和异常追踪。每条追踪以进入处理器时提取的 TraceRecord 缓存，批处理不再遍历 pdata。
+// 正常追踪的完整数据交给 payload 存储，超过内存上限后溢出到磁盘段文件；
+// 异常、新类型和不完整追踪大多会被保留，完整数据始终保存在内存中。
 type SharedBuffer struct {
-	limit          uint64
+	limits         BufferLimits
+	storeConfig    PayloadStoreConfig
+	payloads       *payloadStore
+	sizer          ptrace.ProtoMarshaler
 	mutex          sync.Mutex
-	typeMap        map[string][]ptrace.Traces // Key: typeID, Value: 该类型下的正常追踪列表
-	abnormalTraces []ptrace.Traces          // 异常追踪列表
-	count          uint64                   // 缓冲区中的总追踪数
+	typeMap        map[string][]Record // Key: typeID, Value: 该类型下的正常追踪列表
+	abnormalTraces []Record            // 异常追踪列表
+	abnormalClass  []string            // 与 abnormalTraces 一一对应的错误类别
+	novelTraces    []Record            // 新类型或罕见类型的追踪列表，保证保留
+	incomplete     []Record            // 单独分配预算的不完整追踪列表
+	count          uint64              // 缓冲区中的总追踪数
+	spanCount      uint64              // 缓冲区中的总 span 数
+	byteCount      uint64              // 缓冲区中追踪的总字节数 (仅在设置了字节上限时统计)
 }
 
 // NewSharedBuffer 是 SharedBuffer 的构造函数。
-func NewSharedBuffer(limit uint64) *SharedBuffer {
+func NewSharedBuffer(limits BufferLimits, store PayloadStoreConfig) *SharedBuffer {
 	return &SharedBuffer{
-		limit:          limit,
-		typeMap:        make(map[string][]ptrace.Traces),
-		abnormalTraces: make([]ptrace.Traces, 0),
+		limits:         limits,
+		storeConfig:    store,
+		payloads:       newPayloadStore(store),
+		typeMap:        make(map[string][]Record),
+		abnormalTraces: make([]Record, 0),
 	}
 }
 
+// Entry 是一条待缓存的追踪及其进入处理器时提取的记录 (见 BFSEncoder.Ingest)。
+type Entry struct {
+	Trace      ptrace.Traces
+	Record     TraceRecord
+	IsNovel    bool
+	ErrorClass string // 异常追踪的错误类别，未开启错误分类时为空
+	// IsIncomplete 表示该不完整追踪使用单独的预算，放入 Batch.Incomplete，优先于其他分类
+	IsIncomplete bool
+}
+
+// Record 是缓冲区中的一条追踪：不可变的 TraceRecord 加上完整数据的引用，完整数据用 Batch.Load 读回。
+type Record struct {
+	TraceRecord
+	payload payloadRef
+}
+
+// Batch 是一次 SwapAndClear 换出的缓冲区内容。处理完后需调用 Release 删除段文件。
+type Batch struct {
+	Normal          map[string][]Record // Key: typeID, Value: 该类型下的正常追踪列表
+	Abnormal        []Record            // 异常追踪列表
+	AbnormalClasses []string            // 与 Abnormal 一一对应的错误类别
+	Novel           []Record            // 新类型或罕见类型的追踪列表
+	Incomplete      []Record            // 单独分配预算的不完整追踪列表
+	Count           uint64              // 追踪总数
+
+	payloads *payloadStore
+}
+
+// Load 读回一条追踪的完整数据。溢出到磁盘的追踪每次读回都是新的副本，调用方应只读取一次。
+func (b Batch) Load(record Record) (ptrace.Traces, error) {
+	return b.payloads.get(record.payload)
+}
+
+// SpilledBytes 返回本批次溢出到磁盘的 payload 字节数。
+func (b Batch) SpilledBytes() int64 {
+	if b.payloads == nil {
+		return 0
+	}
+	return b.payloads.spilledBytes()
+}
+
+// Release 删除本批次的段文件，之后不能再调用 Load 读回溢出的追踪。
+func (b Batch) Release() error {
+	return b.payloads.release()
+}
+
 // Add 将一条追踪添加到缓冲区。
-// 它根据 isAbnormal 参数将追踪放入不同的存储区。
-func (b *SharedBuffer) Add(typeID string, trace ptrace.Traces, isAbnormal bool) {
+// 它根据 IsIncomplete、IsNovel 和 Record.IsAbnormal 将追踪放入不同的存储区，按此顺序优先。
+// 同时是新类型的异常追踪放入新类型存储区，保证保留，不受错误类别的数量限制。
+// 正常追踪的完整数据写入段文件失败时仍保存在内存中，并返回错误。
+// 计算编码大小和写入段文件都在缓冲区锁外进行，锁只保护存储区的追加和计数。
+func (b *SharedBuffer) Add(entry Entry) error {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	needSize := b.limits.Bytes > 0 || b.storeConfig.MemoryLimit > 0
+	payloads := b.payloads
+	b.mutex.Unlock()
+
+	trace := entry.Trace
+	size := 0
+	if needSize {
+		size = b.sizer.TracesSize(trace)
+	}
+	normal := !entry.IsIncomplete && !entry.IsNovel && !entry.Record.IsAbnormal
+	ref := payloadRef{trace: trace}
+	var err error
+	if normal {
+		ref, err = payloads.put(trace, size)
+	}
 
-	if isAbnormal {
-		b.abnormalTraces = append(b.abnormalTraces, trace)
-	} else {
-		b.typeMap[typeID] = append(b.typeMap[typeID], trace)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.payloads != payloads {
+		// 写入期间缓冲区已换出，payload 属于上一批次的存储，改为保存在内存中
+		ref, err = payloadRef{trace: trace}, nil
+	}
+	record := Record{TraceRecord: entry.Record, payload: ref}
+	switch {
+	case entry.IsIncomplete:
+		b.incomplete = append(b.incomplete, record)
+	case entry.IsNovel:
+		b.novelTraces = append(b.novelTraces, record)
+	case entry.Record.IsAbnormal:
+		b.abnormalTraces = append(b.abnormalTraces, record)
+		b.abnormalClass = append(b.abnormalClass, entry.ErrorClass)
+	default:
+		b.typeMap[record.TypeID] = append(b.typeMap[record.TypeID], record)
 	}
 	b.count++
+	b.spanCount += uint64(entry.Record.SpanCount)
+	if b.limits.Bytes > 0 {
+		b.byteCount += uint64(size)
+	}
+	return err
+}
+
+// SetLimits 修改缓冲区上限，已缓存的追踪保持不变。
+// 新上限小于当前缓存量时，下一次 FullReason 即报告已满。
+func (b *SharedBuffer) SetLimits(limits BufferLimits) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.limits = limits
+}
+
+// Limits 返回当前的缓冲区上限。
+func (b *SharedBuffer) Limits() BufferLimits {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.limits
 }
 
 // IsFull 检查缓冲区是否已满。
 func (b *SharedBuffer) IsFull() bool {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
-	return b.count >= b.limit
+	return b.fullReason() != ""
+}
+
+// FullReason 返回缓冲区已满的原因 ("traces"、"spans" 或 "bytes")，未满时返回空字符串。
+func (b *SharedBuffer) FullReason() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.fullReason()
+}
+
+func (b *SharedBuffer) fullReason() string {
+	switch {
+	case b.limits.Traces > 0 && b.count >= b.limits.Traces:
+		return "traces"
+	case b.limits.Spans > 0 && b.spanCount >= b.limits.Spans:
+		return "spans"
+	case b.limits.Bytes > 0 && b.byteCount >= b.limits.Bytes:
+		return "bytes"
+	}
+	return ""
 }
 
 // IsEmpty 检查缓冲区是否为空。
@@ -61,20 +197,46 @@ func (b *SharedBuffer) Count() uint64 {
 	return b.count
 }
 
+// SpanCount 返回缓冲区中当前的 span 总数。
+func (b *SharedBuffer) SpanCount() uint64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.spanCount
+}
+
+// ByteCount 返回缓冲区中追踪的总字节数，未设置字节上限时始终为 0。
+func (b *SharedBuffer) ByteCount() uint64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.byteCount
+}
+
 // 这个方法持有锁的时间极短，只在交换指针和计数器时加锁。
-func (b *SharedBuffer) SwapAndClear() (map[string][]ptrace.Traces, []ptrace.Traces, uint64) {
+func (b *SharedBuffer) SwapAndClear() Batch {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
 	// 复制当前数据
-	currentNormalTraces := b.typeMap
-	currentAbnormalTraces := b.abnormalTraces
-	currentCount := b.count
+	batch := Batch{
+		Normal:          b.typeMap,
+		Abnormal:        b.abnormalTraces,
+		AbnormalClasses: b.abnormalClass,
+		Novel:           b.novelTraces,
+		Incomplete:      b.incomplete,
+		Count:           b.count,
+		payloads:        b.payloads,
+	}
 
 	// 立即清空原缓冲区，使其可以接收新的数据
-	b.typeMap = make(map[string][]ptrace.Traces)
-	b.abnormalTraces = make([]ptrace.Traces, 0)
+	b.payloads = newPayloadStore(b.storeConfig)
+	b.typeMap = make(map[string][]Record)
+	b.abnormalTraces = make([]Record, 0)
+	b.abnormalClass = nil
+	b.novelTraces = nil
+	b.incomplete = nil
 	b.count = 0
+	b.spanCount = 0
+	b.byteCount = 0
 
-	return currentNormalTraces, currentAbnormalTraces, currentCount
-}
\ No newline at end of file
+	return batch
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func bufferEntry(spans int) Entry {
+	td := ptrace.NewTraces()
+	ss := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < spans; i++ {
+		ss.AppendEmpty().SetName("op")
+	}
+	return Entry{Trace: td, Record: TraceRecord{Encoding: Encoding{TypeID: "a"}, SpanCount: spans}}
+}
+
+func TestSharedBufferLimits(t *testing.T) {
+	var sizer ptrace.ProtoMarshaler
+	size := uint64(sizer.TracesSize(bufferEntry(2).Trace))
+
+	tests := []struct {
+		name   string
+		limits BufferLimits
+		full   int // 第几条追踪之后缓冲区已满
+		reason string
+	}{
+		{name: "traces", limits: BufferLimits{Traces: 3}, full: 3, reason: "traces"},
+		{name: "spans", limits: BufferLimits{Traces: 100, Spans: 5}, full: 3, reason: "spans"},
+		{name: "bytes", limits: BufferLimits{Traces: 100, Bytes: 2*size + 1}, full: 3, reason: "bytes"},
+		{name: "first limit wins", limits: BufferLimits{Traces: 2, Spans: 5}, full: 2, reason: "traces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := NewSharedBuffer(tt.limits, PayloadStoreConfig{})
+			for i := 1; i <= tt.full; i++ {
+				assert.False(t, buffer.IsFull(), "trace %d", i)
+				require.NoError(t, buffer.Add(bufferEntry(2)))
+			}
+			assert.True(t, buffer.IsFull())
+			assert.Equal(t, tt.reason, buffer.FullReason())
+			assert.Equal(t, uint64(2*tt.full), buffer.SpanCount())
+			if tt.limits.Bytes > 0 {
+				assert.Equal(t, uint64(tt.full)*size, buffer.ByteCount())
+			} else {
+				assert.Zero(t, buffer.ByteCount())
+			}
+
+			batch := buffer.SwapAndClear()
+			assert.Equal(t, uint64(tt.full), batch.Count)
+			assert.True(t, buffer.IsEmpty())
+			assert.Zero(t, buffer.SpanCount())
+			assert.Zero(t, buffer.ByteCount())
+			assert.Empty(t, buffer.FullReason())
+		})
+	}
+}
+
+func TestSharedBufferSetLimits(t *testing.T) {
+	buffer := NewSharedBuffer(BufferLimits{Traces: 10}, PayloadStoreConfig{})
+	require.NoError(t, buffer.Add(bufferEntry(4)))
+	assert.False(t, buffer.IsFull())
+
+	// 新上限小于当前缓存量时立即报告已满
+	buffer.SetLimits(BufferLimits{Traces: 10, Spans: 3})
+	assert.Equal(t, "spans", buffer.FullReason())
+}
+
+func TestSharedBufferNovelAbnormalIsNovel(t *testing.T) {
+	buffer := NewSharedBuffer(BufferLimits{Traces: 10}, PayloadStoreConfig{})
+	entry := bufferEntry(1)
+	entry.Record.IsAbnormal = true
+	entry.IsNovel = true
+	require.NoError(t, buffer.Add(entry))
+
+	// 新类型的异常追踪保证保留，不进入受错误类别限制的异常存储区
+	batch := buffer.SwapAndClear()
+	assert.Len(t, batch.Novel, 1)
+	assert.Empty(t, batch.Abnormal)
+}
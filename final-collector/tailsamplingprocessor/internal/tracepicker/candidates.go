@@ -0,0 +1,403 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/candidates.go
+
+package tracepicker
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// CandidateStrategy 决定如何为每个类型生成遗传算法可选择的候选子集 (AllCombs)。
+type CandidateStrategy string
+
+const (
+	// CandidateRandom 均匀随机抽取候选子集 (与早期版本一致)。
+	CandidateRandom CandidateStrategy = "random"
+	// CandidateStratified 按追踪总延迟的分位数分层，每层抽取一条。
+	CandidateStratified CandidateStrategy = "stratified"
+	// CandidateLatinHypercube 在方差最大的若干标签维度上做拉丁超立方采样，
+	// 每个采样点取秩空间中最近的追踪。
+	CandidateLatinHypercube CandidateStrategy = "latin_hypercube"
+	// CandidateMedoids 用 k-means (k = 配额) 聚类，每个簇取离中心最近的追踪。
+	CandidateMedoids CandidateStrategy = "kmeans_medoids"
+)
+
+const (
+	// candidateMaxDims 是 latin_hypercube 和 kmeans_medoids 使用的最大标签维度数。
+	candidateMaxDims = 8
+	// candidateTailPercentile 是尾部追踪的总延迟分位数，非随机策略保证每个候选子集包含尾部追踪。
+	candidateTailPercentile = 95
+	// kmeansIterations 是每个候选子集的 k-means 迭代次数。
+	kmeansIterations = 10
+)
+
+// Validate 检查策略名称是否有效。
+func (s CandidateStrategy) Validate() error {
+	switch s {
+	case "", CandidateRandom, CandidateStratified, CandidateLatinHypercube, CandidateMedoids:
+		return nil
+	}
+	return fmt.Errorf("unknown candidate strategy %q", s)
+}
+
+// generateCandidates 为下标在 [start, end) 的一个类型生成 count 个候选子集。
+// 每个子集包含 min(quota, end-start) 个不重复的下标，配额超过追踪数时不会 panic。
+func generateCandidates(strategy CandidateStrategy, rawDist [][]float64, start, end, quota, count int, rng *rand.Rand) [][]int {
+	n := end - start
+	if quota > n {
+		quota = n
+	}
+	if quota < 0 {
+		quota = 0
+	}
+
+	sets := make([][]int, count)
+	if strategy == "" || strategy == CandidateRandom || quota == 0 || quota == n {
+		for i := range sets {
+			sets[i] = randomSubset(start, end, quota, rng)
+		}
+		return sets
+	}
+
+	rows := rawDist[start:end]
+	scores := traceScores(rows)
+	tail := tailIndices(scores)
+
+	var ranks [][]float64
+	if strategy == CandidateLatinHypercube || strategy == CandidateMedoids {
+		ranks = rankFeatures(rows, topVarianceDims(rows, candidateMaxDims))
+	}
+
+	for i := range sets {
+		var local []int
+		switch strategy {
+		case CandidateStratified:
+			local = stratifiedSubset(scores, quota, rng)
+		case CandidateLatinHypercube:
+			local = latinHypercubeSubset(ranks, quota, rng)
+		case CandidateMedoids:
+			local = medoidSubset(ranks, quota, rng)
+		default:
+			local = randomSubset(0, n, quota, rng)
+		}
+		local = ensureTail(local, tail, rng)
+
+		set := make([]int, len(local))
+		for j, idx := range local {
+			set[j] = start + idx
+		}
+		sort.Ints(set)
+		sets[i] = set
+	}
+	return sets
+}
+
+// randomSubset 从 [start, end) 中随机抽取 n 个不重复的整数，n 超出范围时取全部。
+func randomSubset(start, end, n int, rng *rand.Rand) []int {
+	size := end - start
+	if n > size {
+		n = size
+	}
+	if n <= 0 {
+		return []int{}
+	}
+
+	population := make([]int, size)
+	for i := range population {
+		population[i] = start + i
+	}
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(size-i)
+		population[i], population[j] = population[j], population[i]
+	}
+	return population[:n]
+}
+
+// traceScores 返回每条追踪的总延迟 (忽略缺失的 NaN 特征)，用于分层和识别尾部追踪。
+func traceScores(rows [][]float64) []float64 {
+	scores := make([]float64, len(rows))
+	for i, row := range rows {
+		for _, v := range row {
+			if !math.IsNaN(v) {
+				scores[i] += v
+			}
+		}
+	}
+	return scores
+}
+
+// tailIndices 返回总延迟不低于 candidateTailPercentile 分位数的追踪下标，至少一条。
+func tailIndices(scores []float64) []int {
+	if len(scores) == 0 {
+		return nil
+	}
+	threshold := percentile(scores, candidateTailPercentile)
+	var tail []int
+	for i, s := range scores {
+		if s >= threshold {
+			tail = append(tail, i)
+		}
+	}
+	return tail
+}
+
+// ensureTail 保证 set 中至少有一条尾部追踪，没有时用随机一条尾部追踪替换一个随机成员。
+func ensureTail(set, tail []int, rng *rand.Rand) []int {
+	if len(set) == 0 || len(tail) == 0 {
+		return set
+	}
+	inSet := make(map[int]struct{}, len(set))
+	for _, idx := range set {
+		inSet[idx] = struct{}{}
+	}
+	for _, idx := range tail {
+		if _, ok := inSet[idx]; ok {
+			return set
+		}
+	}
+	set[rng.Intn(len(set))] = tail[rng.Intn(len(tail))]
+	return set
+}
+
+// stratifiedSubset 按总延迟排序后分成 quota 个等大小的层，每层随机抽取一条。
+func stratifiedSubset(scores []float64, quota int, rng *rand.Rand) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	set := make([]int, quota)
+	n := len(order)
+	for s := 0; s < quota; s++ {
+		lo := s * n / quota
+		hi := (s + 1) * n / quota
+		set[s] = order[lo+rng.Intn(hi-lo)]
+	}
+	return set
+}
+
+// topVarianceDims 返回方差最大的至多 k 个特征列 (忽略 NaN)。
+func topVarianceDims(rows [][]float64, k int) []int {
+	if len(rows) == 0 {
+		return nil
+	}
+	numCols := len(rows[0])
+	variances := make([]float64, numCols)
+	for j := 0; j < numCols; j++ {
+		var n, sum, sumSq float64
+		for _, row := range rows {
+			if v := row[j]; !math.IsNaN(v) {
+				n++
+				sum += v
+				sumSq += v * v
+			}
+		}
+		if n > 0 {
+			mean := sum / n
+			variances[j] = sumSq/n - mean*mean
+		}
+	}
+
+	dims := make([]int, numCols)
+	for j := range dims {
+		dims[j] = j
+	}
+	sort.SliceStable(dims, func(a, b int) bool { return variances[dims[a]] > variances[dims[b]] })
+	if len(dims) > k {
+		dims = dims[:k]
+	}
+	return dims
+}
+
+// rankFeatures 将选定的特征列转换为 [0, 1] 上的归一化秩，缺失值取 0.5。
+// 秩空间使不同量级的标签在距离计算中权重相同。
+func rankFeatures(rows [][]float64, dims []int) [][]float64 {
+	n := len(rows)
+	ranks := make([][]float64, n)
+	for i := range ranks {
+		ranks[i] = make([]float64, len(dims))
+	}
+
+	order := make([]int, n)
+	for d, col := range dims {
+		order = order[:0]
+		for i, row := range rows {
+			if math.IsNaN(row[col]) {
+				ranks[i][d] = 0.5
+				continue
+			}
+			order = append(order, i)
+		}
+		sort.SliceStable(order, func(a, b int) bool { return rows[order[a]][col] < rows[order[b]][col] })
+		for r, i := range order {
+			if len(order) > 1 {
+				ranks[i][d] = float64(r) / float64(len(order)-1)
+			} else {
+				ranks[i][d] = 0.5
+			}
+		}
+	}
+	return ranks
+}
+
+func sqDist(a, b []float64) float64 {
+	var d float64
+	for i := range a {
+		diff := a[i] - b[i]
+		d += diff * diff
+	}
+	return d
+}
+
+// latinHypercubeSubset 在秩空间中生成 quota 个拉丁超立方采样点：
+// 每个维度被分成 quota 段，每段恰好有一个采样点。每个点取最近的未被选中的追踪。
+func latinHypercubeSubset(ranks [][]float64, quota int, rng *rand.Rand) []int {
+	numDims := 0
+	if len(ranks) > 0 {
+		numDims = len(ranks[0])
+	}
+
+	points := make([][]float64, quota)
+	for i := range points {
+		points[i] = make([]float64, numDims)
+	}
+	for d := 0; d < numDims; d++ {
+		perm := rng.Perm(quota)
+		for i := range points {
+			points[i][d] = (float64(perm[i]) + rng.Float64()) / float64(quota)
+		}
+	}
+
+	used := make([]bool, len(ranks))
+	set := make([]int, 0, quota)
+	for _, point := range points {
+		best, bestDist := -1, math.Inf(1)
+		for i, r := range ranks {
+			if used[i] {
+				continue
+			}
+			if d := sqDist(point, r); d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		used[best] = true
+		set = append(set, best)
+	}
+	return set
+}
+
+// medoidSubset 在秩空间中做 k-means (k = quota，k-means++ 初始化)，返回每个簇的中心点 (medoid)。
+// 每次调用使用不同的随机初始化，因此不同候选子集覆盖不同的聚类结果。
+func medoidSubset(ranks [][]float64, quota int, rng *rand.Rand) []int {
+	medoids, _ := kMedoids(ranks, quota, rng)
+	return medoids
+}
+
+// kMedoids 在 points 上做 k-means (k-means++ 初始化)，然后为每个簇选出离中心最近的点作为代表。
+// 返回 k 个不同的代表下标，以及每个代表所在簇的大小。
+func kMedoids(points [][]float64, k int, rng *rand.Rand) (medoids, sizes []int) {
+	n := len(points)
+	centers := make([][]float64, 0, k)
+
+	// k-means++ 初始化
+	first := rng.Intn(n)
+	centers = append(centers, append([]float64(nil), points[first]...))
+	dists := make([]float64, n)
+	for len(centers) < k {
+		var total float64
+		for i, r := range points {
+			dists[i] = math.Inf(1)
+			for _, c := range centers {
+				if d := sqDist(r, c); d < dists[i] {
+					dists[i] = d
+				}
+			}
+			total += dists[i]
+		}
+		next := rng.Intn(n)
+		if total > 0 {
+			target := rng.Float64() * total
+			for i, d := range dists {
+				target -= d
+				if target <= 0 {
+					next = i
+					break
+				}
+			}
+		}
+		centers = append(centers, append([]float64(nil), points[next]...))
+	}
+
+	assign := make([]int, n)
+	counts := make([]int, k)
+	for iter := 0; iter < kmeansIterations; iter++ {
+		for i, r := range points {
+			best, bestDist := 0, math.Inf(1)
+			for c, center := range centers {
+				if d := sqDist(r, center); d < bestDist {
+					best, bestDist = c, d
+				}
+			}
+			assign[i] = best
+		}
+		for c := range centers {
+			counts[c] = 0
+			for d := range centers[c] {
+				centers[c][d] = 0
+			}
+		}
+		for i, r := range points {
+			c := assign[i]
+			counts[c]++
+			for d, v := range r {
+				centers[c][d] += v
+			}
+		}
+		for c := range centers {
+			if counts[c] == 0 {
+				copy(centers[c], points[rng.Intn(n)])
+				continue
+			}
+			for d := range centers[c] {
+				centers[c][d] /= float64(counts[c])
+			}
+		}
+	}
+
+	// 最后一次分配的簇大小
+	for c := range counts {
+		counts[c] = 0
+	}
+	for _, r := range points {
+		best, bestDist := 0, math.Inf(1)
+		for c, center := range centers {
+			if d := sqDist(r, center); d < bestDist {
+				best, bestDist = c, d
+			}
+		}
+		counts[best]++
+	}
+
+	// 每个中心取最近的未被选中的点，空簇或重复时仍能得到 k 个不同的代表
+	used := make([]bool, n)
+	medoids = make([]int, 0, k)
+	sizes = make([]int, 0, k)
+	for c, center := range centers {
+		best, bestDist := -1, math.Inf(1)
+		for i, r := range points {
+			if used[i] {
+				continue
+			}
+			if d := sqDist(r, center); d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		used[best] = true
+		medoids = append(medoids, best)
+		sizes = append(sizes, counts[c])
+	}
+	return medoids, sizes
+}
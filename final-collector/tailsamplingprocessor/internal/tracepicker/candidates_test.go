@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func candidateRows(n int) [][]float64 {
+	rows := make([][]float64, n)
+	for i := range rows {
+		rows[i] = []float64{float64(i), float64((i * 7) % n), float64(n - i)}
+	}
+	// 最后一条是尾部追踪
+	rows[n-1] = []float64{1000, 1000, 1000}
+	return rows
+}
+
+func TestGenerateCandidatesIncludeTail(t *testing.T) {
+	rows := candidateRows(50)
+	rng := rand.New(rand.NewSource(1))
+	tail := tailIndices(traceScores(rows))
+	require.Contains(t, tail, 49)
+
+	for _, strategy := range []CandidateStrategy{CandidateStratified, CandidateLatinHypercube, CandidateMedoids} {
+		sets := generateCandidates(strategy, rows, 0, len(rows), 5, 20, rng)
+		require.Len(t, sets, 20, strategy)
+		for _, set := range sets {
+			assert.Len(t, set, 5, strategy)
+			seen := make(map[int]bool)
+			for _, idx := range set {
+				assert.False(t, seen[idx], "duplicate index in %s", strategy)
+				seen[idx] = true
+			}
+			hasTail := false
+			for _, idx := range tail {
+				hasTail = hasTail || seen[idx]
+			}
+			assert.True(t, hasTail, "no tail trace in %s: %v", strategy, set)
+		}
+	}
+}
+
+func TestGenerateCandidatesQuotaLargerThanPopulation(t *testing.T) {
+	rows := candidateRows(10)
+	rng := rand.New(rand.NewSource(1))
+
+	for _, strategy := range []CandidateStrategy{CandidateRandom, CandidateStratified, CandidateLatinHypercube, CandidateMedoids} {
+		sets := generateCandidates(strategy, rows, 5, 8, 10, 3, rng)
+		for _, set := range sets {
+			assert.ElementsMatch(t, []int{5, 6, 7}, set, strategy)
+		}
+	}
+}
+
+func TestNewSampleProblemClampsQuotas(t *testing.T) {
+	rows := candidateRows(6)
+	problem, err := NewSampleProblem(rows, nil, []int{5, 1}, []int{2, 4}, 4, 1, CandidateStratified, DefaultObjective())
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, problem.Quotas)
+	assert.Equal(t, 3, problem.C)
+}
@@ -0,0 +1,49 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/cluster_sampler.go
+
+package tracepicker
+
+import (
+	"math/rand"
+)
+
+// clusterMaxDims 是聚类使用的最大标签维度数 (按方差选取)。
+const clusterMaxDims = 16
+
+// ClusterSample 是遗传算法之外的另一种采样方式：在每个类型内对延迟向量做 k-medoids 聚类
+// (k 等于该类型的配额)，每个簇保留一个代表。
+// rawDist 的行按类型连续排列，bases 是每个类型的追踪数，quotas 通常来自 AllocateQuota。
+// 返回选中的下标，以及每个代表所在簇的大小 (即它代表的追踪数)。
+// 聚类在方差最大的至多 clusterMaxDims 个特征列的归一化秩上进行，缺失值不影响距离。
+func ClusterSample(rawDist [][]float64, quotas, bases []int, rng *rand.Rand) (selected, weights []int) {
+	quotas = clampQuotas(quotas, bases)
+
+	start := 0
+	for i, quota := range quotas {
+		end := start + bases[i]
+		n := end - start
+
+		switch {
+		case quota <= 0:
+		case quota >= n:
+			// 配额覆盖全部追踪，每条追踪只代表自己
+			for idx := start; idx < end; idx++ {
+				selected = append(selected, idx)
+				weights = append(weights, 1)
+			}
+		default:
+			rows := rawDist[start:end]
+			ranks := rankFeatures(rows, topVarianceDims(rows, clusterMaxDims))
+			medoids, sizes := kMedoids(ranks, quota, rng)
+			for j, m := range medoids {
+				selected = append(selected, start+m)
+				size := sizes[j]
+				if size < 1 {
+					size = 1
+				}
+				weights = append(weights, size)
+			}
+		}
+		start = end
+	}
+	return selected, weights
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterSamplePicksOnePerCluster(t *testing.T) {
+	// 类型 0: 两个明显分开的延迟簇；类型 1: 配额超过追踪数
+	rawDist := [][]float64{
+		{10, 1}, {11, 1}, {12, 1}, {10, 2},
+		{500, 40}, {510, 41},
+		{3, 3}, {4, 4},
+	}
+	selected, weights := ClusterSample(rawDist, []int{2, 5}, []int{6, 2}, rand.New(rand.NewSource(1)))
+
+	assert.Len(t, selected, 4)
+	fast, slow := 0, 0
+	for _, idx := range selected[:2] {
+		if idx < 4 {
+			fast++
+		} else {
+			slow++
+		}
+	}
+	assert.Equal(t, 1, fast)
+	assert.Equal(t, 1, slow)
+	assert.ElementsMatch(t, []int{4, 2}, weights[:2])
+	assert.Equal(t, []int{6, 7}, selected[2:])
+	assert.Equal(t, []int{1, 1}, weights[2:])
+}
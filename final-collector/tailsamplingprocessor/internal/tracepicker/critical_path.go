@@ -0,0 +1,132 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/critical_path.go
+
+package tracepicker
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// CriticalPath 是一条追踪的关键路径：从结束时刻向前回溯，每一段时间归属于当时仍在执行、
+// 且最晚结束的子 span (递归)，没有子 span 覆盖的时间归属于父 span 本身。
+// 并行的子 span 中只有决定父 span 结束时刻的那条计入，扇出不会使路径变长。
+type CriticalPath struct {
+	// Length 是关键路径的总长度，等于根 span 的耗时。
+	// 没有唯一的根时 (森林)，等于所有根覆盖的时间范围，根之间的空隙不归属于任何 span。
+	Length time.Duration
+	// Gaps 是森林中根之间不归属于任何 span 的空隙总长，单棵树时为 0。
+	// Length - Gaps 等于 Contributions 之和。
+	Gaps time.Duration
+	// Contributions 是每个 span 在关键路径上的耗时，与输入的 spans 一一对应。
+	Contributions []time.Duration
+}
+
+// ComputeCriticalPath 计算 spans 的关键路径。
+func ComputeCriticalPath(spans []ptrace.Span) CriticalPath {
+	cp := CriticalPath{Contributions: make([]time.Duration, len(spans))}
+	if len(spans) == 0 {
+		return cp
+	}
+
+	byID := make(map[pcommon.SpanID]struct{}, len(spans))
+	for _, span := range spans {
+		byID[span.SpanID()] = struct{}{}
+	}
+	children := make(map[pcommon.SpanID][]int)
+	var roots []int
+	for i, span := range spans {
+		parent := span.ParentSpanID()
+		if _, ok := byID[parent]; parent.IsEmpty() || !ok {
+			roots = append(roots, i)
+			continue
+		}
+		children[parent] = append(children[parent], i)
+	}
+
+	// 所有 span 的父 span 都在追踪内 (自引用或父子成环) 时没有根，关键路径为空
+	if len(roots) == 0 {
+		return cp
+	}
+
+	w := &cpWalker{spans: spans, children: children, cp: &cp, visited: make([]bool, len(spans))}
+	if len(roots) == 1 {
+		root := spans[roots[0]]
+		w.walk(roots[0], root.StartTimestamp(), root.EndTimestamp())
+		cp.Length = spanDuration(root)
+		return cp
+	}
+
+	// 森林：以覆盖所有根的虚拟根回溯，虚拟根自身的时间 (根之间的空隙) 被丢弃
+	start, end := spans[roots[0]].StartTimestamp(), spans[roots[0]].EndTimestamp()
+	for _, i := range roots[1:] {
+		if s := spans[i].StartTimestamp(); s < start {
+			start = s
+		}
+		if e := spans[i].EndTimestamp(); e > end {
+			end = e
+		}
+	}
+	w.walkChildren(-1, roots, start, end)
+	cp.Length = end.AsTime().Sub(start.AsTime())
+	return cp
+}
+
+// cpWalker 保存关键路径回溯的状态。visited 防止重复的 span ID 造成的环。
+type cpWalker struct {
+	spans    []ptrace.Span
+	children map[pcommon.SpanID][]int
+	cp       *CriticalPath
+	visited  []bool
+}
+
+// walk 将时间窗口 [start, end] 分配给 span i 及其子 span。
+func (w *cpWalker) walk(i int, start, end pcommon.Timestamp) {
+	if w.visited[i] {
+		return
+	}
+	w.visited[i] = true
+	w.walkChildren(i, w.children[w.spans[i].SpanID()], start, end)
+}
+
+// walkChildren 从 end 向前回溯：每次取最晚结束的子 span，把它结束之后的空隙记给 owner，
+// 再把子 span 裁剪到窗口内递归分配，然后游标移到子 span 的开始时刻。owner 为 -1 时空隙记入 Gaps。
+func (w *cpWalker) walkChildren(owner int, kids []int, start, end pcommon.Timestamp) {
+	kids = append([]int(nil), kids...)
+	sort.SliceStable(kids, func(a, b int) bool {
+		return w.spans[kids[a]].EndTimestamp() > w.spans[kids[b]].EndTimestamp()
+	})
+
+	cursor := end
+	for _, k := range kids {
+		if cursor <= start {
+			break
+		}
+		kStart, kEnd := w.spans[k].StartTimestamp(), w.spans[k].EndTimestamp()
+		if kEnd > cursor {
+			kEnd = cursor
+		}
+		if kStart < start {
+			kStart = start
+		}
+		if kEnd <= kStart {
+			continue
+		}
+		w.credit(owner, cursor-kEnd)
+		w.walk(k, kStart, kEnd)
+		cursor = kStart
+	}
+	if cursor > start {
+		w.credit(owner, cursor-start)
+	}
+}
+
+func (w *cpWalker) credit(owner int, d pcommon.Timestamp) {
+	if owner >= 0 {
+		w.cp.Contributions[owner] += time.Duration(d)
+	} else {
+		w.cp.Gaps += time.Duration(d)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func collectSpans(td ptrace.Traces) []ptrace.Span {
+	var spans []ptrace.Span
+	ss := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < ss.Len(); i++ {
+		spans = append(spans, ss.At(i))
+	}
+	return spans
+}
+
+func TestCriticalPathParallelFanOut(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "search", 0, 10000)
+	// profile 和 rate 并行执行，rate 决定了 search 的结束时刻
+	addFeatureSpan(spans, 2, 1, "profile", 1000, 6000)
+	addFeatureSpan(spans, 3, 1, "rate", 1000, 8000)
+	addFeatureSpan(spans, 4, 3, "mongo", 2000, 7000)
+
+	cp := ComputeCriticalPath(collectSpans(td))
+	assert.Equal(t, 10*time.Millisecond, cp.Length)
+	assert.Equal(t, []time.Duration{
+		3 * time.Millisecond, // search: [0,1] 和 [8,10]
+		0,                    // profile 不在关键路径上
+		2 * time.Millisecond, // rate: [1,2] 和 [7,8]
+		5 * time.Millisecond, // mongo: [2,7]
+	}, cp.Contributions)
+
+	var total time.Duration
+	for _, c := range cp.Contributions {
+		total += c
+	}
+	assert.Equal(t, cp.Length, total)
+}
+
+func TestCriticalPathForest(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 2, 1, "get", 0, 3000)
+	addFeatureSpan(spans, 3, 1, "set", 5000, 6000)
+
+	cp := ComputeCriticalPath(collectSpans(td))
+	assert.Equal(t, 6*time.Millisecond, cp.Length)
+	assert.Equal(t, 2*time.Millisecond, cp.Gaps)
+	assert.Equal(t, []time.Duration{3 * time.Millisecond, time.Millisecond}, cp.Contributions)
+}
+
+func TestCriticalPathNoRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans func(spans ptrace.SpanSlice)
+	}{
+		{name: "self parented", spans: func(spans ptrace.SpanSlice) {
+			addFeatureSpan(spans, 1, 1, "loop", 0, 1000)
+		}},
+		{name: "parent cycle", spans: func(spans ptrace.SpanSlice) {
+			addFeatureSpan(spans, 1, 2, "a", 0, 1000)
+			addFeatureSpan(spans, 2, 1, "b", 0, 500)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := ptrace.NewTraces()
+			tt.spans(td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans())
+
+			// 没有根的追踪不会使关键路径计算或编码崩溃
+			cp := ComputeCriticalPath(collectSpans(td))
+			assert.Zero(t, cp.Length)
+			assert.Zero(t, cp.Gaps)
+			assert.Len(t, cp.Contributions, td.SpanCount())
+			record := NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{})).Ingest(td, DefaultFeatureSpec(), registryOf(NewLabelRegistry(0)))
+			assert.Equal(t, "empty_root", record.TypeID)
+		})
+	}
+}
+
+func TestFeatureSpecCriticalPath(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "search", 0, 10000)
+	addFeatureSpan(spans, 2, 1, "profile", 1000, 6000)
+	addFeatureSpan(spans, 3, 1, "rate", 1000, 8000)
+
+	spec := FeatureSpec{Features: []LatencyFeature{FeatureCriticalPath}, Unit: time.Millisecond, TraceCriticalPath: true}
+	assert.Equal(t, []float64{3, 0, 7, 10}, denseFeatures(spec, td, "svc:search", "svc:profile", "svc:rate"))
+	assert.Equal(t, 4, spec.Width(3))
+}
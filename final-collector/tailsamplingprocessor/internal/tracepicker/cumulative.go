@@ -0,0 +1,185 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/cumulative.go
+
+package tracepicker
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cumulativeGamma 是累积直方图对数分桶的相邻边界之比，相对误差约 1%。
+const cumulativeGamma = 1.02
+
+// cumulativeMinWeight 是衰减后保留一个桶的最小权重，更小的桶被丢弃以限制内存。
+const cumulativeMinWeight = 1e-3
+
+// CumulativeSketch 按特征列累积所有批次的原始数据 (seen) 和被采样的数据 (sampled)，
+// 权重按半衰期指数衰减。一致性目标用它比较"至今所有采样 + 本批候选"与"至今所有数据 + 本批数据"，
+// 使连续的批次互相修正偏差，例如每个批次都低估 p99 时后续批次会倾向于保留高延迟的追踪。
+// 特征列按名称 (见 FeatureSpec.ColumnNames) 而不是下标对齐，标签增加时追踪级特征列的下标变化不影响累积数据。
+type CumulativeSketch struct {
+	mutex    sync.Mutex
+	halfLife time.Duration
+	last     time.Time
+	seen     map[string]*decayedHistogram
+	sampled  map[string]*decayedHistogram
+}
+
+// NewCumulativeSketch 是 CumulativeSketch 的构造函数，halfLife 为 0 时不衰减。
+func NewCumulativeSketch(halfLife time.Duration) *CumulativeSketch {
+	return &CumulativeSketch{
+		halfLife: halfLife,
+		seen:     make(map[string]*decayedHistogram),
+		sampled:  make(map[string]*decayedHistogram),
+	}
+}
+
+// Update 先按距上次更新的时间衰减已有权重，再加入一个批次的数据。
+// seen 和 sampled 的每一行是一条追踪的特征向量，列与 columns 一一对应，NaN 表示缺失。
+func (s *CumulativeSketch) Update(now time.Time, columns []string, seen, sampled [][]float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.last.IsZero() && s.halfLife > 0 {
+		factor := math.Pow(0.5, float64(now.Sub(s.last))/float64(s.halfLife))
+		for _, h := range s.seen {
+			h.decay(factor)
+		}
+		for _, h := range s.sampled {
+			h.decay(factor)
+		}
+	}
+	s.last = now
+
+	add := func(hists map[string]*decayedHistogram, rows [][]float64) {
+		for j, column := range columns {
+			h, ok := hists[column]
+			if !ok {
+				h = &decayedHistogram{counts: make(map[int]float64)}
+				hists[column] = h
+			}
+			for _, row := range rows {
+				if j < len(row) {
+					h.add(row[j])
+				}
+			}
+		}
+	}
+	add(s.seen, seen)
+	add(s.sampled, sampled)
+}
+
+// Snapshot 返回 columns 对应的累积数据的只读副本，供一个批次的优化使用。
+func (s *CumulativeSketch) Snapshot(columns []string) *CumulativeSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := &CumulativeSnapshot{
+		seen:    make([][]weightedValue, len(columns)),
+		sampled: make([][]weightedValue, len(columns)),
+	}
+	for j, column := range columns {
+		if h, ok := s.seen[column]; ok {
+			snapshot.seen[j] = h.values()
+		}
+		if h, ok := s.sampled[column]; ok {
+			snapshot.sampled[j] = h.values()
+		}
+	}
+	return snapshot
+}
+
+// CumulativeSnapshot 是 CumulativeSketch 在一个批次开始时的副本，按特征列下标排列。
+type CumulativeSnapshot struct {
+	seen, sampled [][]weightedValue // 每列按值排序
+}
+
+func (s *CumulativeSnapshot) column(j int) (seen, sampled []weightedValue) {
+	if s == nil || j >= len(s.seen) {
+		return nil, nil
+	}
+	return s.seen[j], s.sampled[j]
+}
+
+type weightedValue struct {
+	value, weight float64
+}
+
+// decayedHistogram 是对数分桶的带权直方图。非正值落入单独的零桶。
+type decayedHistogram struct {
+	counts map[int]float64
+}
+
+const zeroBucket = math.MinInt32
+
+func (h *decayedHistogram) add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	key := zeroBucket
+	if v > 0 {
+		key = int(math.Ceil(math.Log(v) / math.Log(cumulativeGamma)))
+	}
+	h.counts[key]++
+}
+
+func (h *decayedHistogram) decay(factor float64) {
+	for key, w := range h.counts {
+		if w *= factor; w < cumulativeMinWeight {
+			delete(h.counts, key)
+		} else {
+			h.counts[key] = w
+		}
+	}
+}
+
+// values 返回每个桶的代表值 (桶区间的中点) 及权重，按值排序。
+func (h *decayedHistogram) values() []weightedValue {
+	values := make([]weightedValue, 0, len(h.counts))
+	for key, w := range h.counts {
+		v := 0.0
+		if key != zeroBucket {
+			v = 2 * math.Pow(cumulativeGamma, float64(key)) / (1 + cumulativeGamma)
+		}
+		values = append(values, weightedValue{value: v, weight: w})
+	}
+	sort.Slice(values, func(a, b int) bool { return values[a].value < values[b].value })
+	return values
+}
+
+// weightedPercentiles 计算累积数据与一列新数据 (权重为 1，NaN 跳过) 合并后的百分位数。
+// 合并后没有数据时返回 nil。
+func weightedPercentiles(cumulative []weightedValue, column []float64, ps []float64) []float64 {
+	merged := make([]weightedValue, 0, len(cumulative)+len(column))
+	merged = append(merged, cumulative...)
+	for _, v := range column {
+		if !math.IsNaN(v) {
+			merged = append(merged, weightedValue{value: v, weight: 1})
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	sort.SliceStable(merged, func(a, b int) bool { return merged[a].value < merged[b].value })
+
+	var total float64
+	for _, wv := range merged {
+		total += wv.weight
+	}
+	result := make([]float64, len(ps))
+	for i, p := range ps {
+		target := p / 100 * total
+		var cum float64
+		result[i] = merged[len(merged)-1].value
+		for _, wv := range merged {
+			cum += wv.weight
+			if cum >= target {
+				result[i] = wv.value
+				break
+			}
+		}
+	}
+	return result
+}
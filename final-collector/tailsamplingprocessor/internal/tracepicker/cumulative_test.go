@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCumulativeSketchDecay(t *testing.T) {
+	s := NewCumulativeSketch(time.Minute)
+	now := time.Unix(0, 0)
+	s.Update(now, []string{"a/last"}, [][]float64{{10}, {10}}, [][]float64{{10}})
+	s.Update(now.Add(time.Minute), []string{"a/last", "b/last"}, [][]float64{{100, math.NaN()}}, nil)
+
+	snapshot := s.Snapshot([]string{"b/last", "a/last", "c/last"})
+	seen, sampled := snapshot.column(1)
+	assert.Len(t, seen, 2)
+	// 一个半衰期后，旧数据权重减半
+	assert.InDelta(t, 10, seen[0].value, 0.2)
+	assert.InDelta(t, 1, seen[0].weight, 1e-9)
+	assert.InDelta(t, 100, seen[1].value, 2)
+	assert.InDelta(t, 1, seen[1].weight, 1e-9)
+	assert.Len(t, sampled, 1)
+	assert.InDelta(t, 0.5, sampled[0].weight, 1e-9)
+
+	seen, sampled = snapshot.column(0)
+	assert.Empty(t, seen)
+	assert.Empty(t, sampled)
+}
+
+func TestObjectiveCumulativeCorrectsBias(t *testing.T) {
+	// 之前的批次都只采样了低延迟的追踪
+	s := NewCumulativeSketch(0)
+	column := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	var seen, sampled [][]float64
+	for i, v := range column {
+		seen = append(seen, []float64{v})
+		if i < 5 {
+			sampled = append(sampled, []float64{v})
+		}
+	}
+	s.Update(time.Now(), []string{"a/last"}, seen, sampled)
+
+	objective := Objective{
+		Distance:         DistancePercentileRMSE,
+		CumulativeWeight: 1,
+		Cumulative:       s.Snapshot([]string{"a/last"}),
+	}
+	origin := objective.prepare(0, column)
+	low := []float64{10, 20, 30, 40, 50}
+	high := []float64{60, 70, 80, 90, 100}
+	// 单看本批次两者同样偏离原始分布，累积项使补偿之前偏差的高延迟采样得分更好
+	assert.Less(t, objective.cumulativeDistance(high, origin), objective.cumulativeDistance(low, origin))
+
+	// 不启用时没有累积项
+	plain := Objective{Distance: DistancePercentileRMSE, Cumulative: objective.Cumulative}
+	assert.Nil(t, plain.prepare(0, column).cumulativeSeen)
+}
@@ -6,11 +6,11 @@ import (
 	"container/list"
 	"crypto/sha1"
 	"encoding/hex"
-	"fmt"
 	"go.opentelemetry.io/collector/pdata/pcommon" // 确保引入 pcommon 包
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"math"
 
@@ -24,12 +24,22 @@ type stat struct {
 	mu, std float64
 }
 
+// Moments 是一个标签延迟的可合并矩统计量 (样本数、和、平方和)。
+// 多个副本的 Moments 可以直接相加，用于计算全局均值和标准差。
+type Moments struct {
+	Count float64 `json:"count"`
+	Sum   float64 `json:"sum"`
+	SumSq float64 `json:"sum_sq"`
+}
+
 // HistPool 存储每个操作的历史延迟数据。
 type HistPool struct {
 	limit    int
 	mutex    sync.RWMutex
 	data     map[string]*list.List // key: label, value: 历史延迟列表
 	db       map[string]stat       // key: label, value: 统计数据
+	remote   map[string]Moments    // key: label, value: 其他副本汇总的矩统计量
+	longTerm map[string]Moments    // key: label, value: 指数衰减的长期矩统计量，用于 Range
 	recalcTh int                   // 重新计算统计数据的阈值
 	count    int                   // 全局计数器
 }
@@ -40,10 +50,18 @@ func NewHistPool(height uint64) *HistPool {
 		limit:    int(height),
 		data:     make(map[string]*list.List),
 		db:       make(map[string]stat),
+		longTerm: make(map[string]Moments),
 		recalcTh: 100, // 初始阈值
 	}
 }
 
+// rangeHorizon 是长期矩统计量的衰减跨度，以滑动窗口长度的倍数计。
+// 每个标签每加入一条记录，旧数据的权重乘以 1 - 1/(rangeHorizon*limit)。
+const rangeHorizon = 10
+
+// rangeSigmas 是 Range 在长期均值两侧取的标准差倍数。
+const rangeSigmas = 3
+
 // Add 添加一条延迟记录，并可能触发统计更新。
 func (p *HistPool) Add(label string, duration time.Duration) {
 	p.mutex.Lock()
@@ -52,10 +70,22 @@ func (p *HistPool) Add(label string, duration time.Duration) {
 	if _, ok := p.data[label]; !ok {
 		p.data[label] = list.New()
 	}
-	p.data[label].PushBack(duration.Seconds() * 1000) // 存为毫秒
+	ms := duration.Seconds() * 1000 // 存为毫秒
+	p.data[label].PushBack(ms)
 	if p.data[label].Len() > p.limit {
 		p.data[label].Remove(p.data[label].Front())
 	}
+	horizon := rangeHorizon * p.limit
+	if horizon < 1 {
+		horizon = 1
+	}
+	decay := 1 - 1/float64(horizon)
+	m := p.longTerm[label]
+	p.longTerm[label] = Moments{
+		Count: m.Count*decay + 1,
+		Sum:   m.Sum*decay + ms,
+		SumSq: m.SumSq*decay + ms*ms,
+	}
 
 	p.count++
 	if p.count >= p.recalcTh {
@@ -77,19 +107,64 @@ func (p *HistPool) getMuStd(label string) (mu, std float64) {
 	return 0, 0
 }
 
+// Range 返回一个标签延迟 (毫秒) 的长期范围：指数衰减的长期均值两侧各 rangeSigmas 个标准差，下限不小于 0。
+// 范围基于 longTerm 而不是滑动窗口，窗口滚动时特征归一化的尺度保持平稳，单个批次的离群值也不会改变它。
+// 没有历史数据时 ok 为 false。
+func (p *HistPool) Range(label string) (lo, hi float64, ok bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	m, exists := p.longTerm[label]
+	if !exists || m.Count <= 0 {
+		return 0, 0, false
+	}
+	mu := m.Sum / m.Count
+	std := math.Sqrt(math.Max(m.SumSq/m.Count-mu*mu, 0))
+	return math.Max(mu-rangeSigmas*std, 0), mu + rangeSigmas*std, true
+}
+
+// Moments 返回本地历史池中每个标签的矩统计量，不包含其他副本的数据。
+func (p *HistPool) Moments() map[string]Moments {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	result := make(map[string]Moments, len(p.data))
+	for label, l := range p.data {
+		result[label] = listMoments(l)
+	}
+	return result
+}
+
+// SetRemoteMoments 设置其他副本汇总的矩统计量，并立即重新计算统计数据。
+// 之后的均值和标准差基于本地数据与远端数据之和。
+func (p *HistPool) SetRemoteMoments(remote map[string]Moments) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.remote = remote
+	p.recalculateAll()
+}
+
 // recalculateAll 更新所有操作的统计数据。
 func (p *HistPool) recalculateAll() {
+	merged := make(map[string]Moments, len(p.data))
 	for label, l := range p.data {
-		var sum, sumSq float64
-		var count float64
-		for e := l.Front(); e != nil; e = e.Next() {
-			val := e.Value.(float64)
-			sum += val
-			sumSq += val * val
-			count++
+		merged[label] = listMoments(l)
+	}
+	for label, r := range p.remote {
+		m := merged[label]
+		m.Count += r.Count
+		m.Sum += r.Sum
+		m.SumSq += r.SumSq
+		merged[label] = m
+	}
+
+	for label, m := range merged {
+		if m.Count <= 0 {
+			continue
 		}
-		mu := sum / count
-		variance := (sumSq / count) - (mu * mu) // 方差
+		mu := m.Sum / m.Count
+		variance := (m.SumSq / m.Count) - (mu * mu) // 方差
 		if variance < 0 {
 			variance = 0 // 避免浮点数精度问题导致负数
 		}
@@ -98,25 +173,115 @@ func (p *HistPool) recalculateAll() {
 	}
 }
 
+// listMoments 计算一个历史延迟列表的矩统计量。
+func listMoments(l *list.List) Moments {
+	var m Moments
+	for e := l.Front(); e != nil; e = e.Next() {
+		val := e.Value.(float64)
+		m.Sum += val
+		m.SumSq += val * val
+		m.Count++
+	}
+	return m
+}
+
 // --- BFSEncoder 实现 ---
 
+// DefaultAbnormalSigma 是异常检测阈值 mu + k*std 中 k 的默认值。
+const DefaultAbnormalSigma = 5.0
+
 // BFSEncoder 负责将 trace 编码为 typeID 并检测异常。
 type BFSEncoder struct {
-	pool *HistPool
+	pool   *HistPool
+	labels *LabelNormalizer
+	sigma  atomic.Uint64 // 异常阈值 mu + k*std 中的 k，以 math.Float64bits 存储，可在运行时修改
 }
 
 // NewBFSEncoder 是 BFSEncoder 的构造函数。
-func NewBFSEncoder(pool *HistPool) *BFSEncoder {
-	return &BFSEncoder{pool: pool}
+func NewBFSEncoder(pool *HistPool, labels *LabelNormalizer) *BFSEncoder {
+	e := &BFSEncoder{pool: pool, labels: labels}
+	e.SetAbnormalSigma(DefaultAbnormalSigma)
+	return e
+}
+
+// SetAbnormalSigma 设置异常阈值 mu + k*std 中的 k，可以与 Encode 并发调用。
+func (e *BFSEncoder) SetAbnormalSigma(k float64) {
+	e.sigma.Store(math.Float64bits(k))
+}
+
+// AbnormalSigma 返回当前的异常阈值系数 k。
+func (e *BFSEncoder) AbnormalSigma() float64 {
+	return math.Float64frombits(e.sigma.Load())
+}
+
+// Encoding 是一条追踪的编码结果。
+type Encoding struct {
+	TypeID     string        // BFS 标签路径的哈希
+	IsAbnormal bool          // 有错误 span 或总耗时超过异常阈值
+	HasError   bool          // 至少一个 span 的状态为 Error
+	RootLabel  string        // 根 span 的标签 (根端点)，没有唯一的根 span 时为空
+	Duration   time.Duration // 根 span 的耗时，没有唯一的根 span 时为所有 span 覆盖的时间范围
+	Incomplete bool          // 根 span 缺失、有多个根或有父 span 缺失的孤立子树，TypeID 为森林编码
 }
 
 // Encode 将 ptrace.Traces 对象编码为 typeID 并判断其是否异常。
 func (e *BFSEncoder) Encode(trace ptrace.Traces) (typeID string, isAbnormal bool) {
-	// 修正：将 ptrace.SpanID 改为 pcommon.SpanID
-	spanMap := make(map[pcommon.SpanID]ptrace.Span)
-	childrenMap := make(map[pcommon.SpanID][]pcommon.SpanID)
-	var rootID pcommon.SpanID
-	var spans []ptrace.Span
+	enc := e.EncodeTrace(trace)
+	return enc.TypeID, enc.IsAbnormal
+}
+
+// EncodeTrace 与 Encode 相同，但返回完整的编码结果。
+func (e *BFSEncoder) EncodeTrace(trace ptrace.Traces) Encoding {
+	ts := e.collect(trace)
+	return e.encode(ts, ComputeCriticalPath(ts.spans))
+}
+
+// TraceRecord 是一条追踪进入处理器时一次遍历得到的不可变记录，缓冲区与之后的批处理只使用它，
+// 不再遍历 pdata，也不会再次把 span 耗时加入 HistPool。
+type TraceRecord struct {
+	Encoding
+	TraceIDs   []pcommon.TraceID // 追踪中出现的追踪 ID，不重复
+	SpanCount  int
+	ErrorSpans int           // 状态为 Error 的 span 数
+	Features   FeatureRecord // 紧凑特征，未提取特征时为空
+}
+
+// Ingest 一次遍历追踪，同时完成编码和特征提取：span 列表、标签和关键路径只计算一次，
+// 每个 span 的耗时只加入 HistPool 一次。编码完成后用 registryFor 根据编码结果选择登记标签的 registry，
+// 追踪中的标签登记到其中；返回 nil 时不提取特征，也不登记标签。
+func (e *BFSEncoder) Ingest(trace ptrace.Traces, features FeatureSpec, registryFor func(Encoding) *LabelRegistry) TraceRecord {
+	ts := e.collect(trace)
+	cp := ComputeCriticalPath(ts.spans)
+	record := TraceRecord{
+		Encoding:   e.encode(ts, cp),
+		TraceIDs:   ts.traceIDs,
+		SpanCount:  len(ts.spans),
+		ErrorSpans: ts.errorSpans,
+	}
+	if registry := registryFor(record.Encoding); registry != nil {
+		aggs := features.aggregateSpans(ts.spans, ts.labels, cp, registry.Register)
+		record.Features = features.record(aggs, cp)
+	}
+	return record
+}
+
+// traceSpans 是一次遍历追踪得到的 span 及其标签，编码和特征提取共用。
+type traceSpans struct {
+	spans       []ptrace.Span
+	labels      []string // 与 spans 一一对应
+	spanMap     map[pcommon.SpanID]int
+	childrenMap map[pcommon.SpanID][]pcommon.SpanID
+	traceIDs    []pcommon.TraceID
+	errorSpans  int
+}
+
+// collect 遍历追踪的所有 span，计算每个 span 的标签。
+func (e *BFSEncoder) collect(trace ptrace.Traces) *traceSpans {
+	ts := &traceSpans{
+		spanMap:     make(map[pcommon.SpanID]int),
+		childrenMap: make(map[pcommon.SpanID][]pcommon.SpanID),
+	}
+	seen := make(map[pcommon.TraceID]struct{})
 
 	rs := trace.ResourceSpans()
 	for i := 0; i < rs.Len(); i++ {
@@ -138,76 +303,143 @@ func (e *BFSEncoder) Encode(trace ptrace.Traces) (typeID string, isAbnormal bool
 				// 这是一个简化处理，更好的方式是直接传递 resource 对象或 serviceName
 				span.Attributes().PutStr("service.name", serviceName)
 
-				spans = append(spans, span)
-				spanMap[span.SpanID()] = span
-				if span.ParentSpanID().IsEmpty() {
-					rootID = span.SpanID()
-				} else {
-					childrenMap[span.ParentSpanID()] = append(childrenMap[span.ParentSpanID()], span.SpanID())
+				ts.spanMap[span.SpanID()] = len(ts.spans)
+				ts.spans = append(ts.spans, span)
+				ts.labels = append(ts.labels, e.labels.Label(span))
+				if !span.ParentSpanID().IsEmpty() {
+					ts.childrenMap[span.ParentSpanID()] = append(ts.childrenMap[span.ParentSpanID()], span.SpanID())
+				}
+				if span.Status().Code() == ptrace.StatusCodeError {
+					ts.errorSpans++
+				}
+				if _, ok := seen[span.TraceID()]; !ok {
+					seen[span.TraceID()] = struct{}{}
+					ts.traceIDs = append(ts.traceIDs, span.TraceID())
 				}
 			}
 		}
 	}
+	return ts
+}
+
+// encode 基于一次遍历的结果生成编码，cp 是 ts.spans 的关键路径。
+func (e *BFSEncoder) encode(ts *traceSpans, cp CriticalPath) Encoding {
+	spans := ts.spans
 
 	// 1. 异常检测
-	var expectedDurationMs, trueDurationMs float64
-	var hasError bool
-	for _, span := range spans {
-		if span.Status().Code() == ptrace.StatusCodeError {
-			hasError = true
-		}
-		label := getSpanLabel(span)
+	// 真实耗时是关键路径的长度，期望耗时是关键路径上每个 span 的阈值 mu + k*std
+	// 按其在关键路径上的占比累加。不在关键路径上的并行子 span 不计入两者。
+	// 森林中根之间的空隙没有对应的期望耗时，也不计入真实耗时
+	var expectedDurationMs float64
+	hasError := ts.errorSpans > 0
+	sigma := e.AbnormalSigma()
+	trueDurationMs := (cp.Length - cp.Gaps).Seconds() * 1000
+	for i, span := range spans {
+		label := ts.labels[i]
 		duration := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
 		e.pool.Add(label, duration)
+		if cp.Contributions[i] <= 0 || duration <= 0 {
+			continue
+		}
 		mu, std := e.pool.getMuStd(label)
-		expectedDurationMs += mu + 5*std // 对应 Python 的 mu + 5 * std
-		trueDurationMs += float64(duration.Milliseconds())
+		share := float64(cp.Contributions[i]) / float64(duration)
+		expectedDurationMs += (mu + sigma*std) * share // 对应 Python 的 mu + 5 * std
+	}
+	enc := Encoding{
+		IsAbnormal: hasError || (trueDurationMs > expectedDurationMs && expectedDurationMs > 0),
+		HasError:   hasError,
+		Duration:   traceDuration(spans),
 	}
-	isAbnormal = hasError || (trueDurationMs > expectedDurationMs && expectedDurationMs > 0)
 
 	// 2. BFS 编码生成 typeID
-	if rootID.IsEmpty() {
-		return "empty_root", isAbnormal
+	// 父 span 为空或不在本追踪中的 span 都是一棵子树的根。只有一个真正的根时与单棵树的编码相同，
+	// 否则 (根缺失、多个根或有孤立子树) 对每棵子树分别编码，按签名排序后组合，并标记为不完整
+	var roots, trueRoots []int
+	for i, span := range spans {
+		if span.ParentSpanID().IsEmpty() {
+			roots = append(roots, i)
+			trueRoots = append(trueRoots, i)
+		} else if _, ok := ts.spanMap[span.ParentSpanID()]; !ok {
+			roots = append(roots, i)
+		}
+	}
+	if len(roots) == 0 {
+		enc.TypeID = "empty_root"
+		return enc
+	}
+	if len(trueRoots) == 1 {
+		enc.RootLabel = ts.labels[trueRoots[0]]
+		enc.Duration = spanDuration(spans[trueRoots[0]])
+	}
+	if len(roots) == 1 && len(trueRoots) == 1 {
+		enc.TypeID = hashSignature(strings.Join(ts.bfsPath(spans[trueRoots[0]].SpanID()), "->"))
+		return enc
+	}
+
+	enc.Incomplete = true
+	subtrees := make([]string, len(roots))
+	for i, root := range roots {
+		sig := strings.Join(ts.bfsPath(spans[root].SpanID()), "->")
+		if !spans[root].ParentSpanID().IsEmpty() {
+			sig = "?" + sig // 父 span 缺失的孤立子树
+		}
+		subtrees[i] = sig
 	}
+	sort.Strings(subtrees)
+	enc.TypeID = hashSignature(strings.Join(subtrees, "||"))
+	return enc
+}
 
+// bfsPath 从 rootID 开始逐层遍历子树，每层按标签排序，返回标签序列。
+func (ts *traceSpans) bfsPath(rootID pcommon.SpanID) []string {
 	var path []string
 	queue := []pcommon.SpanID{rootID}
 
 	for len(queue) > 0 {
 		levelSize := len(queue)
-		levelNodes := []ptrace.Span{}
+		levelNodes := []int{}
 		for i := 0; i < levelSize; i++ {
-			if node, ok := spanMap[queue[i]]; ok {
+			if node, ok := ts.spanMap[queue[i]]; ok {
 				levelNodes = append(levelNodes, node)
 			}
 		}
 		queue = queue[levelSize:]
 
 		sort.Slice(levelNodes, func(i, j int) bool {
-			return getSpanLabel(levelNodes[i]) < getSpanLabel(levelNodes[j])
+			return ts.labels[levelNodes[i]] < ts.labels[levelNodes[j]]
 		})
 
 		for _, node := range levelNodes {
-			path = append(path, getSpanLabel(node))
-			if children, ok := childrenMap[node.SpanID()]; ok {
+			path = append(path, ts.labels[node])
+			if children, ok := ts.childrenMap[ts.spans[node].SpanID()]; ok {
 				queue = append(queue, children...)
 			}
 		}
 	}
 
-	pathString := strings.Join(path, "->")
-	h := sha1.New()
-	h.Write([]byte(pathString))
-	typeID = hex.EncodeToString(h.Sum(nil))
+	return path
+}
 
-	return typeID, isAbnormal
+// hashSignature 返回类型签名的 SHA-1 十六进制摘要，作为 typeID。
+func hashSignature(signature string) string {
+	h := sha1.New()
+	h.Write([]byte(signature))
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-// getSpanLabel 从 span 中提取 "service:operation" 标签。
-func getSpanLabel(span ptrace.Span) string {
-	serviceName := "unknown.service"
-	if val, ok := span.Attributes().Get("service.name"); ok {
-		serviceName = val.Str()
+// traceDuration 返回所有 span 覆盖的时间范围。
+func traceDuration(spans []ptrace.Span) time.Duration {
+	if len(spans) == 0 {
+		return 0
 	}
-	return fmt.Sprintf("%s:%s", serviceName, span.Name())
-}
\ No newline at end of file
+	start, end := spans[0].StartTimestamp(), spans[0].EndTimestamp()
+	for _, span := range spans[1:] {
+		if span.StartTimestamp() < start {
+			start = span.StartTimestamp()
+		}
+		if span.EndTimestamp() > end {
+			end = span.EndTimestamp()
+		}
+	}
+	return end.AsTime().Sub(start.AsTime())
+}
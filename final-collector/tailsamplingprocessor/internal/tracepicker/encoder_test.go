@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestEncodeTraceRootAndErrors(t *testing.T) {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "svc")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "root", 0, 10000)
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	spans.At(1).Status().SetCode(ptrace.StatusCodeError)
+
+	e := NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{}))
+	enc := e.EncodeTrace(td)
+
+	assert.Equal(t, "svc:root", enc.RootLabel)
+	assert.Equal(t, 10*time.Millisecond, enc.Duration)
+	assert.True(t, enc.HasError)
+	assert.True(t, enc.IsAbnormal)
+
+	typeID, isAbnormal := e.Encode(td)
+	assert.Equal(t, enc.TypeID, typeID)
+	assert.True(t, isAbnormal)
+}
+
+func TestEncodeTraceForest(t *testing.T) {
+	e := NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{}))
+
+	complete := ptrace.NewTraces()
+	spans := complete.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "proxy", 0, 10000)
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	addFeatureSpan(spans, 3, 1, "set", 5000, 8000)
+	assert.False(t, e.EncodeTrace(complete).Incomplete)
+
+	// 根 span 缺失，两棵子树挂在同一个缺失的父 span 上
+	orphaned := ptrace.NewTraces()
+	spans = orphaned.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 3, 1, "set", 5000, 8000)
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	enc := e.EncodeTrace(orphaned)
+	assert.True(t, enc.Incomplete)
+	assert.Empty(t, enc.RootLabel)
+	assert.Equal(t, 7*time.Millisecond, enc.Duration)
+	assert.NotEqual(t, "empty_root", enc.TypeID)
+
+	// 子树顺序不影响编码
+	reordered := ptrace.NewTraces()
+	spans = reordered.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	addFeatureSpan(spans, 3, 1, "set", 5000, 8000)
+	assert.Equal(t, enc.TypeID, e.EncodeTrace(reordered).TypeID)
+
+	// 不同的孤立子树得到不同的类型
+	other := ptrace.NewTraces()
+	spans = other.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	assert.NotEqual(t, enc.TypeID, e.EncodeTrace(other).TypeID)
+
+	// 有根但中间的 span 缺失，根端点仍然可用
+	gap := ptrace.NewTraces()
+	spans = gap.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "proxy", 0, 10000)
+	addFeatureSpan(spans, 4, 9, "query", 2000, 3000)
+	enc = e.EncodeTrace(gap)
+	assert.True(t, enc.Incomplete)
+	assert.Equal(t, "unknown.service:proxy", enc.RootLabel)
+}
+
+func TestEncodeTraceForestGapsNotAbnormal(t *testing.T) {
+	e := NewBFSEncoder(NewHistPool(100), NewLabelNormalizer(NormalizerConfig{}))
+	forest := func(gap int64) ptrace.Traces {
+		td := ptrace.NewTraces()
+		spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+		addFeatureSpan(spans, 2, 1, "get", 0, 1000)
+		addFeatureSpan(spans, 3, 1, "set", 1000+gap, 2000+gap)
+		return td
+	}
+	// HistPool 每 100 条延迟重新计算一次统计量
+	for i := 0; i < 60; i++ {
+		e.EncodeTrace(forest(0))
+	}
+
+	// 两棵子树都和平时一样快，它们之间的长空隙不应使追踪被判为异常
+	enc := e.EncodeTrace(forest(100000))
+	assert.True(t, enc.Incomplete)
+	assert.False(t, enc.IsAbnormal)
+}
+
+func TestHistPoolRangeOutlivesWindow(t *testing.T) {
+	pool := NewHistPool(2)
+	_, _, ok := pool.Range("svc:a")
+	assert.False(t, ok)
+
+	for i := 0; i < 100; i++ {
+		pool.Add("svc:a", 10*time.Millisecond)
+	}
+	pool.Add("svc:a", time.Second)
+	pool.Add("svc:a", time.Second)
+
+	// 滑动窗口里只剩两个 1s 的离群值，长期范围仍然覆盖平时的 10ms
+	lo, hi, ok := pool.Range("svc:a")
+	assert.True(t, ok)
+	assert.LessOrEqual(t, lo, 10.0)
+	assert.Greater(t, hi, 10.0)
+	assert.Less(t, hi, 1000.0*rangeSigmas)
+}
+
+func TestIngestSinglePass(t *testing.T) {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "svc")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "root", 0, 10000)
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	addFeatureSpan(spans, 3, 1, "get", 2000, 6000)
+	spans.At(2).Status().SetCode(ptrace.StatusCodeError)
+	for i := 0; i < spans.Len(); i++ {
+		spans.At(i).SetTraceID(pcommon.TraceID{7})
+	}
+
+	spec := FeatureSpec{Features: []LatencyFeature{FeatureMax, FeatureCount}, Unit: time.Microsecond, TraceCriticalPath: true}
+	pool := NewHistPool(10)
+	registry := NewLabelRegistry(0)
+	record := NewBFSEncoder(pool, NewLabelNormalizer(NormalizerConfig{})).Ingest(td, spec, registryOf(registry))
+
+	// 每个 span 的耗时只加入 HistPool 一次
+	moments := pool.Moments()
+	assert.Equal(t, float64(1), moments["svc:root"].Count)
+	assert.Equal(t, float64(2), moments["svc:get"].Count)
+
+	assert.Equal(t, []pcommon.TraceID{{7}}, record.TraceIDs)
+	assert.Equal(t, 3, record.SpanCount)
+	assert.Equal(t, 1, record.ErrorSpans)
+	assert.True(t, record.HasError)
+
+	// 编码与单独编码的结果一致，标签按出现顺序登记
+	enc := NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{})).EncodeTrace(td)
+	assert.Equal(t, enc, record.Encoding)
+	assert.Equal(t, FeatureRecord{Labels: []int{0, 1}, Values: []float64{10000, 1, 4000, 2}, Trace: 10000}, record.Features)
+	assert.Equal(t, 2, registry.Len())
+}
+
+func TestIngestWithoutRegistry(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "root", 0, 10000)
+
+	// registryFor 看到的是本条追踪的编码，返回 nil 时不提取特征
+	var seen Encoding
+	record := NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{})).Ingest(td, DefaultFeatureSpec(), func(enc Encoding) *LabelRegistry {
+		seen = enc
+		return nil
+	})
+	assert.Equal(t, record.Encoding, seen)
+	assert.Empty(t, record.Features.Labels)
+}
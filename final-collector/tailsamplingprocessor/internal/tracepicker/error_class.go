@@ -0,0 +1,95 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/error_class.go
+
+package tracepicker
+
+import (
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// LatencyErrorClass 是没有错误 span、仅因延迟超出基线而被判为异常的追踪的错误类别。
+const LatencyErrorClass = "latency"
+
+// ErrorClassifier 为异常追踪计算错误类别签名。
+// 签名由每个出错 span 的标签、状态码和选定的错误属性组成，
+// 例如 memcached 的 503 与 mongo 查询的 500 会得到不同的签名。
+type ErrorClassifier struct {
+	labels     *LabelNormalizer
+	attributes []string // 参与签名的属性，例如 http.status_code、exception.type
+}
+
+// NewErrorClassifier 是 ErrorClassifier 的构造函数。
+func NewErrorClassifier(labels *LabelNormalizer, attributes []string) *ErrorClassifier {
+	return &ErrorClassifier{labels: labels, attributes: attributes}
+}
+
+// Classify 返回追踪的错误类别签名。没有出错 span 时返回 LatencyErrorClass。
+func (c *ErrorClassifier) Classify(trace ptrace.Traces) string {
+	parts := make(map[string]struct{})
+
+	rs := trace.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ils := rs.At(i).ScopeSpans()
+		for j := 0; j < ils.Len(); j++ {
+			spans := ils.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if span.Status().Code() != ptrace.StatusCodeError {
+					continue
+				}
+				parts[c.spanSignature(span)] = struct{}{}
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return LatencyErrorClass
+	}
+
+	sigs := make([]string, 0, len(parts))
+	for sig := range parts {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+	return strings.Join(sigs, ";")
+}
+
+// spanSignature 返回一个出错 span 的签名: "label|status|key=value,..."。
+// 属性先在 span 上查找，找不到时再在 exception 事件上查找。
+func (c *ErrorClassifier) spanSignature(span ptrace.Span) string {
+	var b strings.Builder
+	b.WriteString(c.labels.Label(span))
+	b.WriteString("|")
+	b.WriteString(span.Status().Code().String())
+
+	for _, key := range c.attributes {
+		val, ok := lookupErrorAttribute(span, key)
+		if !ok {
+			continue
+		}
+		b.WriteString("|")
+		b.WriteString(key)
+		b.WriteString("=")
+		b.WriteString(val.AsString())
+	}
+	return b.String()
+}
+
+func lookupErrorAttribute(span ptrace.Span, key string) (pcommon.Value, bool) {
+	if val, ok := span.Attributes().Get(key); ok {
+		return val, true
+	}
+	events := span.Events()
+	for i := 0; i < events.Len(); i++ {
+		if events.At(i).Name() != "exception" {
+			continue
+		}
+		if val, ok := events.At(i).Attributes().Get(key); ok {
+			return val, true
+		}
+	}
+	return pcommon.Value{}, false
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func newErrorTrace(service, name string, code int64) ptrace.Traces {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	root := spans.AppendEmpty()
+	root.SetName("root")
+	root.Attributes().PutStr("service.name", "frontend")
+
+	span := spans.AppendEmpty()
+	span.SetName(name)
+	span.Attributes().PutStr("service.name", service)
+	span.Attributes().PutInt("http.status_code", code)
+	span.Status().SetCode(ptrace.StatusCodeError)
+	return td
+}
+
+func TestErrorClassifierSignatures(t *testing.T) {
+	c := NewErrorClassifier(NewLabelNormalizer(NormalizerConfig{}), []string{"http.status_code", "exception.type"})
+
+	memcached := c.Classify(newErrorTrace("memcached", "get", 503))
+	mongo := c.Classify(newErrorTrace("mongo", "find", 500))
+
+	assert.Equal(t, "memcached:get|Error|http.status_code=503", memcached)
+	assert.NotEqual(t, memcached, mongo)
+	assert.Equal(t, memcached, c.Classify(newErrorTrace("memcached", "get", 503)))
+}
+
+func TestErrorClassifierExceptionEvent(t *testing.T) {
+	c := NewErrorClassifier(NewLabelNormalizer(NormalizerConfig{}), []string{"exception.type"})
+
+	td := newErrorTrace("mongo", "find", 500)
+	span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(1)
+	event := span.Events().AppendEmpty()
+	event.SetName("exception")
+	event.Attributes().PutStr("exception.type", "TimeoutError")
+
+	assert.Equal(t, "mongo:find|Error|exception.type=TimeoutError", c.Classify(td))
+}
+
+func TestErrorClassifierLatencyOnly(t *testing.T) {
+	c := NewErrorClassifier(NewLabelNormalizer(NormalizerConfig{}), nil)
+
+	td := ptrace.NewTraces()
+	td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetName("slow")
+	assert.Equal(t, LatencyErrorClass, c.Classify(td))
+}
@@ -0,0 +1,301 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/features.go
+
+package tracepicker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// LatencyFeature 是延迟矩阵中每个标签的一个特征列。
+type LatencyFeature string
+
+const (
+	// FeatureLast 取该标签最后一个 span 的耗时 (与早期版本一致)。
+	FeatureLast LatencyFeature = "last"
+	// FeatureSum 取该标签所有 span 的耗时之和。
+	FeatureSum LatencyFeature = "sum"
+	// FeatureMax 取该标签所有 span 中的最大耗时。
+	FeatureMax LatencyFeature = "max"
+	// FeatureCount 取该标签在追踪中出现的次数。
+	FeatureCount LatencyFeature = "count"
+	// FeatureSelfTime 取该标签所有 span 的独占耗时之和 (扣除子 span 覆盖的时间)。
+	FeatureSelfTime LatencyFeature = "self_time"
+	// FeatureCriticalPath 取该标签所有 span 在追踪关键路径上的耗时之和。
+	FeatureCriticalPath LatencyFeature = "critical_path"
+)
+
+// FeatureSpec 描述如何从一条追踪中提取每个标签的延迟特征。
+type FeatureSpec struct {
+	Features []LatencyFeature // 每个标签的特征列，按顺序排列
+	Unit     time.Duration    // 耗时的单位，例如 time.Millisecond 或 time.Microsecond
+	// TraceCriticalPath 在特征向量末尾追加一列追踪级特征：关键路径的总长度。
+	TraceCriticalPath bool
+}
+
+// DefaultFeatureSpec 返回与早期版本一致的特征：每个标签一列，毫秒精度。
+func DefaultFeatureSpec() FeatureSpec {
+	return FeatureSpec{Features: []LatencyFeature{FeatureLast}, Unit: time.Millisecond}
+}
+
+// Validate 检查特征配置是否有效。
+func (f FeatureSpec) Validate() error {
+	if len(f.Features) == 0 {
+		return fmt.Errorf("at least one latency feature is required")
+	}
+	for _, feature := range f.Features {
+		switch feature {
+		case FeatureLast, FeatureSum, FeatureMax, FeatureCount, FeatureSelfTime, FeatureCriticalPath:
+		default:
+			return fmt.Errorf("unknown latency feature %q", feature)
+		}
+	}
+	if f.Unit <= 0 {
+		return fmt.Errorf("latency unit must be positive")
+	}
+	return nil
+}
+
+// labelAgg 累积一个标签在一条追踪中的所有 span。
+type labelAgg struct {
+	count       int
+	last        time.Duration
+	sum         time.Duration
+	max         time.Duration
+	selfTimeSum time.Duration
+	critical    time.Duration
+}
+
+// Width 返回 numLabels 个标签的特征向量长度。
+func (f FeatureSpec) Width(numLabels int) int {
+	width := numLabels * len(f.Features)
+	if f.TraceCriticalPath {
+		width++
+	}
+	return width
+}
+
+// ColumnNames 返回每个特征列的名称 ("标签/特征"，追踪级特征为 "trace/critical_path")，
+// 与 Dense 的列一一对应。标签集合变化时名称保持不变，可以跨批次对齐特征列。
+func (f FeatureSpec) ColumnNames(labels []string) []string {
+	names := make([]string, 0, f.Width(len(labels)))
+	for _, label := range labels {
+		for _, feature := range f.Features {
+			names = append(names, label+"/"+string(feature))
+		}
+	}
+	if f.TraceCriticalPath {
+		names = append(names, "trace/"+string(FeatureCriticalPath))
+	}
+	return names
+}
+
+// ColumnRanges 返回每个特征列基于 HistPool 长期历史的标准化范围，与 Dense 的列一一对应。
+// 只有取值在单个 span 耗时范围内的特征 (last、max、self_time) 有历史范围，
+// 其余列以及没有历史数据的标签返回 NaN，由当前批次的数据决定范围。
+func (f FeatureSpec) ColumnRanges(labels []string, pool *HistPool) []ColumnRange {
+	ranges := make([]ColumnRange, f.Width(len(labels)))
+	for i := range ranges {
+		ranges[i] = ColumnRange{Min: math.NaN(), Max: math.NaN()}
+	}
+	width := len(f.Features)
+	scale := float64(time.Millisecond) / float64(f.Unit)
+	for i, label := range labels {
+		lo, hi, ok := pool.Range(label)
+		if !ok {
+			continue
+		}
+		for j, feature := range f.Features {
+			switch feature {
+			case FeatureLast, FeatureMax:
+				ranges[i*width+j] = ColumnRange{Min: lo * scale, Max: hi * scale}
+			case FeatureSelfTime:
+				ranges[i*width+j] = ColumnRange{Min: 0, Max: hi * scale}
+			}
+		}
+	}
+	return ranges
+}
+
+// FeatureRecord 是一条追踪的紧凑特征：只保存追踪中出现的标签。
+// 标签下标来自 LabelRegistry，批次处理时用 Dense 展开为任意宽度的特征向量，
+// 因此追踪进入缓冲区之后新登记的标签不影响已有的记录。
+type FeatureRecord struct {
+	Labels []int     // 追踪中出现的标签的下标，升序
+	Values []float64 // 每个标签 len(Features) 个特征值，与 Labels 对应
+	Trace  float64   // 追踪级特征 (关键路径长度)，未开启 TraceCriticalPath 时为 0
+}
+
+// record 将按标签下标汇总的 span 转换为紧凑特征。
+func (f FeatureSpec) record(aggs map[int]*labelAgg, cp CriticalPath) FeatureRecord {
+	record := FeatureRecord{Labels: make([]int, 0, len(aggs))}
+	for idx := range aggs {
+		record.Labels = append(record.Labels, idx)
+	}
+	sort.Ints(record.Labels)
+	record.Values = make([]float64, 0, len(record.Labels)*len(f.Features))
+	for _, idx := range record.Labels {
+		for _, feature := range f.Features {
+			record.Values = append(record.Values, f.value(aggs[idx], feature))
+		}
+	}
+	if f.TraceCriticalPath {
+		record.Trace = f.toUnit(cp.Length)
+	}
+	return record
+}
+
+// Dense 将紧凑特征展开为长度为 Width(numLabels) 的特征向量，下标不小于 numLabels 的标签被忽略。
+// 追踪中不存在的标签取 NaN，count 特征取 0。
+func (f FeatureSpec) Dense(record FeatureRecord, numLabels int) []float64 {
+	width := len(f.Features)
+	result := make([]float64, f.Width(numLabels))
+	for i := 0; i < numLabels; i++ {
+		for j, feature := range f.Features {
+			result[i*width+j] = f.value(nil, feature)
+		}
+	}
+	for k, idx := range record.Labels {
+		if idx < numLabels {
+			copy(result[idx*width:(idx+1)*width], record.Values[k*width:(k+1)*width])
+		}
+	}
+	if f.TraceCriticalPath {
+		result[len(result)-1] = record.Trace
+	}
+	return result
+}
+
+// aggregateSpans 按标签汇总一条追踪的 span，labels 与 spans 一一对应。
+// lookup 返回标签的下标，返回 false 的标签被忽略。不需要关键路径特征时 cp 可以为空。
+func (f FeatureSpec) aggregateSpans(spans []ptrace.Span, labels []string, cp CriticalPath, lookup func(label string) (int, bool)) map[int]*labelAgg {
+	var selfTimes map[pcommon.SpanID]time.Duration
+	if f.has(FeatureSelfTime) {
+		selfTimes = computeSelfTimes(spans)
+	}
+
+	aggs := make(map[int]*labelAgg)
+	for i, span := range spans {
+		idx, ok := lookup(labels[i])
+		if !ok {
+			continue
+		}
+		agg, ok := aggs[idx]
+		if !ok {
+			agg = &labelAgg{}
+			aggs[idx] = agg
+		}
+		duration := spanDuration(span)
+		agg.count++
+		agg.last = duration
+		agg.sum += duration
+		if duration > agg.max {
+			agg.max = duration
+		}
+		if selfTimes != nil {
+			agg.selfTimeSum += selfTimes[span.SpanID()]
+		}
+		if cp.Contributions != nil {
+			agg.critical += cp.Contributions[i]
+		}
+	}
+	return aggs
+}
+
+func (f FeatureSpec) has(feature LatencyFeature) bool {
+	for _, fe := range f.Features {
+		if fe == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func (f FeatureSpec) value(agg *labelAgg, feature LatencyFeature) float64 {
+	if agg == nil {
+		if feature == FeatureCount {
+			return 0
+		}
+		return math.NaN()
+	}
+	switch feature {
+	case FeatureSum:
+		return f.toUnit(agg.sum)
+	case FeatureMax:
+		return f.toUnit(agg.max)
+	case FeatureCount:
+		return float64(agg.count)
+	case FeatureSelfTime:
+		return f.toUnit(agg.selfTimeSum)
+	case FeatureCriticalPath:
+		return f.toUnit(agg.critical)
+	default:
+		return f.toUnit(agg.last)
+	}
+}
+
+// toUnit 将耗时换算为整数个 Unit。
+func (f FeatureSpec) toUnit(d time.Duration) float64 {
+	return float64(d / f.Unit)
+}
+
+func spanDuration(span ptrace.Span) time.Duration {
+	return span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
+}
+
+// computeSelfTimes 计算每个 span 的独占耗时：自身耗时减去子 span 覆盖的时间。
+// 并行的子 span 按时间区间的并集扣除，不会重复扣除重叠部分。
+func computeSelfTimes(spans []ptrace.Span) map[pcommon.SpanID]time.Duration {
+	type interval struct{ start, end pcommon.Timestamp }
+	children := make(map[pcommon.SpanID][]interval)
+	for _, span := range spans {
+		if !span.ParentSpanID().IsEmpty() {
+			children[span.ParentSpanID()] = append(children[span.ParentSpanID()],
+				interval{span.StartTimestamp(), span.EndTimestamp()})
+		}
+	}
+
+	result := make(map[pcommon.SpanID]time.Duration, len(spans))
+	for _, span := range spans {
+		start, end := span.StartTimestamp(), span.EndTimestamp()
+		self := spanDuration(span)
+
+		ivs := children[span.SpanID()]
+		sort.Slice(ivs, func(i, j int) bool { return ivs[i].start < ivs[j].start })
+
+		// 合并子 span 区间并裁剪到父 span 范围内
+		var covered time.Duration
+		var curStart, curEnd pcommon.Timestamp
+		for _, iv := range ivs {
+			s, e := iv.start, iv.end
+			if s < start {
+				s = start
+			}
+			if e > end {
+				e = end
+			}
+			if e <= s {
+				continue
+			}
+			if s > curEnd {
+				covered += time.Duration(curEnd - curStart)
+				curStart, curEnd = s, e
+			} else if e > curEnd {
+				curEnd = e
+			}
+		}
+		covered += time.Duration(curEnd - curStart)
+
+		self -= covered
+		if self < 0 {
+			self = 0
+		}
+		result[span.SpanID()] = self
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// addFeatureSpan 向追踪中添加一个 span，start/end 以微秒为单位。
+func addFeatureSpan(spans ptrace.SpanSlice, id, parent byte, name string, start, end int64) {
+	span := spans.AppendEmpty()
+	span.SetName(name)
+	span.SetSpanID(pcommon.SpanID{id})
+	if parent != 0 {
+		span.SetParentSpanID(pcommon.SpanID{parent})
+	}
+	span.Attributes().PutStr("service.name", "svc")
+	base := time.Unix(0, 0)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(base.Add(time.Duration(start) * time.Microsecond)))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(base.Add(time.Duration(end) * time.Microsecond)))
+}
+
+// registryOf 返回一个总是使用 registry 的 BFSEncoder.Ingest 参数。
+func registryOf(registry *LabelRegistry) func(Encoding) *LabelRegistry {
+	return func(Encoding) *LabelRegistry { return registry }
+}
+
+// ingestFeatures 用 BFSEncoder.Ingest 提取一条追踪的紧凑特征，标签登记到 registry。
+// BFSEncoder 使用资源上的 service.name，这里把它设为 addFeatureSpan 使用的 "svc"。
+func ingestFeatures(spec FeatureSpec, td ptrace.Traces, registry *LabelRegistry) FeatureRecord {
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		rs.At(i).Resource().Attributes().PutStr("service.name", "svc")
+	}
+	return NewBFSEncoder(NewHistPool(10), NewLabelNormalizer(NormalizerConfig{})).Ingest(td, spec, registryOf(registry)).Features
+}
+
+// denseFeatures 提取一条追踪的特征向量，labels 按顺序预先登记，追踪中其他标签的列被忽略。
+func denseFeatures(spec FeatureSpec, td ptrace.Traces, labels ...string) []float64 {
+	registry := NewLabelRegistry(0)
+	for _, label := range labels {
+		registry.Register(label)
+	}
+	return spec.Dense(ingestFeatures(spec, td, registry), len(labels))
+}
+
+func TestFeatureSpecExtract(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "root", 0, 10000)
+	// 两个并行的子调用，区间 [1000,5000) 与 [2000,6000) 重叠
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	addFeatureSpan(spans, 3, 1, "get", 2000, 6000)
+	addFeatureSpan(spans, 4, 1, "fast", 7000, 7400)
+
+	spec := FeatureSpec{
+		Features: []LatencyFeature{FeatureSum, FeatureMax, FeatureCount, FeatureSelfTime},
+		Unit:     time.Microsecond,
+	}
+
+	got := denseFeatures(spec, td, "svc:root", "svc:get", "svc:fast", "svc:missing")
+
+	// root: 独占耗时 = 10000 - 并集 [1000,6000) - [7000,7400)
+	assert.Equal(t, []float64{10000, 10000, 1, 4600}, got[0:4])
+	assert.Equal(t, []float64{8000, 4000, 2, 8000}, got[4:8])
+	assert.Equal(t, []float64{400, 400, 1, 400}, got[8:12])
+	assert.True(t, math.IsNaN(got[12]))
+	assert.Equal(t, float64(0), got[14])
+}
+
+func TestFeatureSpecDenseIgnoresLaterLabels(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "root", 0, 10000)
+	addFeatureSpan(spans, 2, 1, "get", 1000, 5000)
+	addFeatureSpan(spans, 3, 1, "get", 2000, 6000)
+
+	registry := NewLabelRegistry(0)
+	registry.Register("svc:missing")
+	spec := FeatureSpec{
+		Features:          []LatencyFeature{FeatureMax, FeatureCount},
+		Unit:              time.Microsecond,
+		TraceCriticalPath: true,
+	}
+
+	record := ingestFeatures(spec, td, registry)
+	assert.Equal(t, []int{1, 2}, record.Labels)
+
+	// 之后登记的标签不影响记录，展开时按缺失处理
+	registry.Register("svc:later")
+	got := spec.Dense(record, registry.Len())
+	assert.Len(t, got, 9)
+	assert.True(t, math.IsNaN(got[0]))
+	assert.Equal(t, []float64{0, 10000, 1, 4000, 2}, got[1:6])
+	assert.True(t, math.IsNaN(got[6]))
+	assert.Equal(t, []float64{0, 10000}, got[7:9])
+}
+
+func TestFeatureSpecMillisecondsTruncate(t *testing.T) {
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	addFeatureSpan(spans, 1, 0, "fast", 0, 400)
+
+	got := denseFeatures(DefaultFeatureSpec(), td, "svc:fast")
+	assert.Equal(t, []float64{0}, got)
+}
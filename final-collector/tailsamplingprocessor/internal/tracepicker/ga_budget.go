@@ -0,0 +1,84 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/ga_budget.go
+
+package tracepicker
+
+import (
+	"time"
+
+	"github.com/MaxHalford/eaopt"
+)
+
+// StopReason 是遗传算法结束的原因。
+type StopReason string
+
+const (
+	// StopGenerations 表示跑满了最大代数。
+	StopGenerations StopReason = "generations"
+	// StopDeadline 表示用完了时间预算，返回至今找到的最优解。
+	StopDeadline StopReason = "deadline"
+	// StopConverged 表示最优适应度连续 Patience 代没有改善。
+	StopConverged StopReason = "converged"
+	// StopFailed 表示优化失败，返回的是随机的回退解。
+	StopFailed StopReason = "failed"
+)
+
+// OptimizeBudget 限制一次优化的代数和时间。为 0 的字段使用优化器的默认值或不限制。
+type OptimizeBudget struct {
+	// Generations 是最大代数，为 0 时使用优化器的默认代数。
+	Generations uint
+	// TimeBudget 是一次优化的时间上限，为 0 时不限制。
+	// 只在代与代之间检查，初始种群的评估和正在进行的一代不会被打断。
+	TimeBudget time.Duration
+	// Patience 是提前停止前允许最优适应度没有改善的代数，为 0 时不提前停止。
+	Patience uint
+}
+
+// OptimizeStats 记录一次优化实际运行的情况。
+type OptimizeStats struct {
+	Generations uint
+	Elapsed     time.Duration
+	StopReason  StopReason
+	BestFitness float64
+}
+
+// applyBudget 按预算设置 GA 的代数和提前停止条件，返回的函数在优化结束后给出统计信息。
+func applyBudget(config *eaopt.GAConfig, budget OptimizeBudget) func(ga *eaopt.GA) OptimizeStats {
+	if budget.Generations > 0 {
+		config.NGenerations = budget.Generations
+	}
+
+	start := time.Now()
+	var (
+		reason   = StopGenerations
+		best     float64
+		hasBest  bool
+		stagnant uint
+	)
+	// EarlyStop 在初始种群之后和每一代之后各调用一次
+	config.EarlyStop = func(ga *eaopt.GA) bool {
+		if len(ga.HallOfFame) > 0 {
+			if fitness := ga.HallOfFame[0].Fitness; !hasBest || fitness < best {
+				best, hasBest, stagnant = fitness, true, 0
+			} else {
+				stagnant++
+			}
+		}
+		if budget.TimeBudget > 0 && time.Since(start) >= budget.TimeBudget {
+			reason = StopDeadline
+			return true
+		}
+		if budget.Patience > 0 && stagnant >= budget.Patience {
+			reason = StopConverged
+			return true
+		}
+		return false
+	}
+
+	return func(ga *eaopt.GA) OptimizeStats {
+		stats := OptimizeStats{Generations: ga.Generations, Elapsed: time.Since(start), StopReason: reason}
+		if len(ga.HallOfFame) > 0 {
+			stats.BestFitness = ga.HallOfFame[0].Fitness
+		}
+		return stats
+	}
+}
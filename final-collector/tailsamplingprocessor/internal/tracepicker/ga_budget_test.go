@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/MaxHalford/eaopt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// constantGenome 的适应度恒定，优化不会有任何改善。
+type constantGenome struct{ delay time.Duration }
+
+func (g *constantGenome) Evaluate() (float64, error) {
+	time.Sleep(g.delay)
+	return 1, nil
+}
+func (g *constantGenome) Mutate(*rand.Rand)                  {}
+func (g *constantGenome) Crossover(eaopt.Genome, *rand.Rand) {}
+func (g *constantGenome) Clone() eaopt.Genome                { return &constantGenome{delay: g.delay} }
+
+func runBudgeted(t *testing.T, budget OptimizeBudget, delay time.Duration) OptimizeStats {
+	config := eaopt.NewDefaultGAConfig()
+	config.NPops = 1
+	config.PopSize = 4
+	config.NGenerations = 1000
+	config.ParallelEval = false
+	finish := applyBudget(&config, budget)
+	ga, err := config.NewGA()
+	require.NoError(t, err)
+	require.NoError(t, ga.Minimize(func(*rand.Rand) eaopt.Genome { return &constantGenome{delay: delay} }))
+	return finish(ga)
+}
+
+func TestApplyBudget(t *testing.T) {
+	stats := runBudgeted(t, OptimizeBudget{Generations: 7}, 0)
+	assert.Equal(t, StopGenerations, stats.StopReason)
+	assert.Equal(t, uint(7), stats.Generations)
+
+	stats = runBudgeted(t, OptimizeBudget{Patience: 3}, 0)
+	assert.Equal(t, StopConverged, stats.StopReason)
+	assert.Equal(t, uint(3), stats.Generations)
+	assert.Equal(t, 1.0, stats.BestFitness)
+
+	stats = runBudgeted(t, OptimizeBudget{TimeBudget: 20 * time.Millisecond}, time.Millisecond)
+	assert.Equal(t, StopDeadline, stats.StopReason)
+	assert.Less(t, stats.Generations, uint(1000))
+}
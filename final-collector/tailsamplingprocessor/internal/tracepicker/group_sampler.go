@@ -25,10 +25,10 @@ func NewCombination(comb []int) *Combination {
 // SampleProblem 分组采样优化问题
 type SampleProblem struct {
 	// 基本参数
-	Quotas   []int       // 每个代码的采样配额
-	Bases    []int       // 每个代码的存储数量
-	RawDist  [][]float64 // 候选数据的延迟分布 (numTrace, numLabel)
-	AbDist   [][]float64 // 异常数据的延迟分布 (numAbTrace, numLabel)
+	Quotas  []int       // 每个代码的采样配额
+	Bases   []int       // 每个代码的存储数量
+	RawDist [][]float64 // 候选数据的延迟分布 (numTrace, numLabel)
+	AbDist  [][]float64 // 异常数据的延迟分布 (numAbTrace, numLabel)
 
 	// 计算后的属性
 	Splits   []int
@@ -47,29 +47,50 @@ type SampleProblem struct {
 	Lbin      []int // 1: 包含下界
 	Ubin      []int // 1: 包含上界
 
+	// 一致性目标
+	Objective Objective
+	origin    []columnStats // 每个特征列原始数据的统计量 (numLabel)
+
 	// 百分位数相关
 	Ps      []float64   // 百分位数点
-	OriginP [][]float64 // 原始数据的百分位数 (numLabel, 8)
+	OriginP [][]float64 // 原始数据标准化后的百分位数 (numLabel, len(Ps))，仅 percentile_rmse
 	MaxV    []float64   // 每个标签的最大值
 	MinV    []float64   // 每个标签的最小值
 
 	// 当前批次大小
 	Np int
+
+	// 候选子集的生成策略
+	Strategy CandidateStrategy
+
+	// Seeds 是播种候选子集使用的精英，未播种时为 nil
+	Seeds [][]Strata
 }
 
 // NewSampleProblem 创建新的SampleProblem实例
-func NewSampleProblem(rawDist, abDist [][]float64, quotas, bases []int, combCount, M int) (*SampleProblem, error) {
+// 配额超过该类型的追踪数时按追踪数截断。
+func NewSampleProblem(rawDist, abDist [][]float64, quotas, bases []int, combCount, M int,
+	strategy CandidateStrategy, objective Objective) (*SampleProblem, error) {
 	if combCount < 2 {
 		return nil, fmt.Errorf("combCount must be larger than 2")
 	}
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := objective.Validate(); err != nil {
+		return nil, err
+	}
 
 	sp := &SampleProblem{
-		Quotas:  quotas,
-		Bases:   bases,
-		RawDist: rawDist,
-		AbDist:  abDist,
-		M:       M,
+		Quotas:    clampQuotas(quotas, bases),
+		Bases:     bases,
+		RawDist:   rawDist,
+		AbDist:    abDist,
+		M:         M,
+		Strategy:  strategy,
+		Objective: objective,
 	}
+	quotas = sp.Quotas
 
 	// 计算累积和
 	sp.Splits = make([]int, len(bases))
@@ -89,25 +110,7 @@ func NewSampleProblem(rawDist, abDist [][]float64, quotas, bases []int, combCoun
 
 	// 初始化组合
 	initStart := time.Now()
-	sp.AllCombs = make([][]*Combination, combCount)
-
-	for combIdx := 0; combIdx < combCount; combIdx++ {
-		sp.AllCombs[combIdx] = make([]*Combination, len(quotas))
-
-		for i, quota := range quotas {
-			var start, end int
-			if i == 0 {
-				start = 0
-			} else {
-				start = sp.Splits[i-1]
-			}
-			end = sp.Splits[i]
-
-			// 随机采样
-			indices := randomSample(start, end, quota)
-			sp.AllCombs[combIdx][i] = NewCombination(indices)
-		}
-	}
+	sp.AllCombs = buildCombinations(strategy, rawDist, quotas, sp.Splits, combCount)
 
 	initEnd := time.Now()
 	fmt.Printf("[SAMPLE] the time consuming of init is %.2f seconds\n", initEnd.Sub(initStart).Seconds())
@@ -123,46 +126,21 @@ func NewSampleProblem(rawDist, abDist [][]float64, quotas, bases []int, combCoun
 	sp.Ubin = make([]int, sp.Dim)
 
 	for i := 0; i < sp.Dim; i++ {
-		sp.VarTypes[i] = 1        // 离散变量
+		sp.VarTypes[i] = 1       // 离散变量
 		sp.Lb[i] = 0             // 下界
 		sp.Ub[i] = combCount - 1 // 上界
 		sp.Lbin[i] = 1           // 包含下界
 		sp.Ubin[i] = 1           // 包含上界
 	}
 
-	// 计算百分位数数据
-	sp.Ps = []float64{0, 25, 50, 75, 90, 95, 99, 100}
-
+	// 计算原始数据 (正常追踪与异常追踪) 每个特征列的统计量
 	var origin [][]float64
 	if len(abDist) > 0 {
 		origin = append(rawDist, abDist...)
 	} else {
 		origin = rawDist
 	}
-
-	// 转置数据 (numTrace, numLabel) -> (numLabel, numTrace)
-	originT := transpose(origin)
-
-	// 计算百分位数
-	sp.OriginP = make([][]float64, sp.NumLabel)
-	sp.MaxV = make([]float64, sp.NumLabel)
-	sp.MinV = make([]float64, sp.NumLabel)
-
-	for i := 0; i < sp.NumLabel; i++ {
-		sp.OriginP[i] = make([]float64, len(sp.Ps))
-		for j, p := range sp.Ps {
-			sp.OriginP[i][j] = percentile(originT[i], p)
-		}
-		sp.MaxV[i] = max(originT[i])
-		sp.MinV[i] = minFloat(originT[i])
-	}
-
-	// 标准化 OriginP
-	for i := 0; i < sp.NumLabel; i++ {
-		for j := 0; j < len(sp.Ps); j++ {
-			sp.OriginP[i][j] = (sp.OriginP[i][j] - sp.MinV[i]) / (sp.MaxV[i] - sp.MinV[i] + 1e-7)
-		}
-	}
+	sp.Ps, sp.OriginP, sp.MinV, sp.MaxV, sp.origin = prepareOrigin(objective, transpose(origin), sp.NumLabel)
 
 	return sp, nil
 }
@@ -181,18 +159,29 @@ func (sp *SampleProblem) RandomPhen(n int) [][]int {
 
 // Consistency 计算一致性
 func (sp *SampleProblem) Consistency(matrix [][]float64) []float64 {
-	// matrix: (Np * numLabel, C)
+	return sp.consistency(matrix, sp.Np)
+}
+
+// consistency 与 Consistency 相同，但使用给定的组数 np，不读取也不修改 sp.Np。
+func (sp *SampleProblem) consistency(matrix [][]float64, np int) []float64 {
+	return consistencyScore(matrix, np, sp.AbDist, sp.NumLabel, sp.Objective, sp.origin)
+}
+
+// consistencyScore 计算 np 组采样数据的一致性误差。matrix 的形状是 (np * numLabel, C)，
+// 每行是一组采样在一个特征列上的数据；异常追踪 abDist 拼接到每一组之后，
+// 与原始分布的距离按列权重求和。
+func consistencyScore(matrix [][]float64, np int, abDist [][]float64, numLabel int, objective Objective, origin []columnStats) []float64 {
 	var sample [][]float64
 
-	if len(sp.AbDist) > 0 {
+	if len(abDist) > 0 {
 		// 转置 AbDist
-		abDistT := transpose(sp.AbDist)
+		abDistT := transpose(abDist)
 		// 复制 abDistT Np 次
-		tileAbDist := make([][]float64, sp.Np*sp.NumLabel)
-		for i := 0; i < sp.Np; i++ {
-			for j := 0; j < sp.NumLabel; j++ {
-				tileAbDist[i*sp.NumLabel+j] = make([]float64, len(abDistT[j]))
-				copy(tileAbDist[i*sp.NumLabel+j], abDistT[j])
+		tileAbDist := make([][]float64, np*numLabel)
+		for i := 0; i < np; i++ {
+			for j := 0; j < numLabel; j++ {
+				tileAbDist[i*numLabel+j] = make([]float64, len(abDistT[j]))
+				copy(tileAbDist[i*numLabel+j], abDistT[j])
 			}
 		}
 
@@ -205,51 +194,10 @@ func (sp *SampleProblem) Consistency(matrix [][]float64) []float64 {
 		sample = matrix
 	}
 
-	// 计算百分位数
-	sampleP := make([][]float64, len(sample))
+	result := make([]float64, np)
 	for i := 0; i < len(sample); i++ {
-		sampleP[i] = make([]float64, len(sp.Ps))
-		for j, p := range sp.Ps {
-			sampleP[i][j] = percentile(sample[i], p)
-		}
-	}
-
-	// 复制 originP Np 次
-	originP := make([][]float64, sp.Np*sp.NumLabel)
-	for i := 0; i < sp.Np; i++ {
-		for j := 0; j < sp.NumLabel; j++ {
-			originP[i*sp.NumLabel+j] = make([]float64, len(sp.OriginP[j]))
-			copy(originP[i*sp.NumLabel+j], sp.OriginP[j])
-		}
-	}
-
-	// 标准化 sampleP
-	for i := 0; i < len(sampleP); i++ {
-		labelIdx := i % sp.NumLabel
-		for j := 0; j < len(sampleP[i]); j++ {
-			sampleP[i][j] = (sampleP[i][j] - sp.MinV[labelIdx]) / (sp.MaxV[labelIdx] - sp.MinV[labelIdx] + 1e-7)
-		}
-	}
-
-	// 计算 RMSE
-	mse := make([]float64, len(sampleP))
-	for i := 0; i < len(sampleP); i++ {
-		sum := 0.0
-		for j := 0; j < len(sampleP[i]); j++ {
-			diff := sampleP[i][j] - originP[i][j]
-			sum += diff * diff
-		}
-		mse[i] = sum / float64(len(sampleP[i]))
-	}
-
-	// 重新组织结果 (Np, numLabel)
-	result := make([]float64, sp.Np)
-	for i := 0; i < sp.Np; i++ {
-		sum := 0.0
-		for j := 0; j < sp.NumLabel; j++ {
-			sum += mse[i*sp.NumLabel+j]
-		}
-		result[i] = sum
+		labelIdx := i % numLabel
+		result[i/numLabel] += objective.columnWeight(labelIdx) * objective.distance(sample[i], origin[labelIdx])
 	}
 
 	return result
@@ -398,8 +346,14 @@ func NewSampleOptimizer(problem *SampleProblem, config *OptimizeConfig) *SampleO
 }
 
 // createSampleVectorFactory 创建SampleVector工厂函数
+// 问题已播种时，第一个个体是上一批次最优解的重建 (全 0 的基因)。
 func (so *SampleOptimizer) createSampleVectorFactory() func(*rand.Rand) eaopt.Genome {
+	seeded := so.Problem.Seeds == nil
 	return func(rng *rand.Rand) eaopt.Genome {
+		if !seeded {
+			seeded = true
+			return NewSampleVector(make([]int, so.Problem.Dim), so.Problem)
+		}
 		genes := make([]int, so.Problem.Dim)
 		for i := 0; i < so.Problem.Dim; i++ {
 			genes[i] = rng.Intn(so.Problem.Ub[i]-so.Problem.Lb[i]+1) + so.Problem.Lb[i]
@@ -412,13 +366,13 @@ func (so *SampleOptimizer) createSampleVectorFactory() func(*rand.Rand) eaopt.Ge
 func (so *SampleOptimizer) Optimize() (*SampleVector, error) {
 	// 配置遗传算法
 	config := eaopt.NewDefaultGAConfig()
-	
+
 	// 设置参数
 	config.PopSize = so.Config.PopSize
 	config.NGenerations = so.Config.NGenerations
 	config.HofSize = so.Config.HofSize
 	config.ParallelEval = false
-	
+
 	// 设置模型 - 使用带交叉率和变异率的代际模型
 	config.Model = eaopt.ModGenerational{
 		Selector:  eaopt.SelTournament{NContestants: 3},
@@ -457,13 +411,13 @@ func (so *SampleOptimizer) Optimize() (*SampleVector, error) {
 func (so *SampleOptimizer) OptimizeWithCallback(callback func(generation uint, bestFitness float64)) (*SampleVector, error) {
 	// 配置遗传算法
 	config := eaopt.NewDefaultGAConfig()
-	
+
 	// 设置参数
 	config.PopSize = so.Config.PopSize
 	config.NGenerations = so.Config.NGenerations
 	config.HofSize = so.Config.HofSize
 	config.ParallelEval = false
-	
+
 	// 设置模型 - 使用带交叉率和变异率的代际模型
 	config.Model = eaopt.ModGenerational{
 		Selector:  eaopt.SelTournament{NContestants: 3},
@@ -509,10 +463,48 @@ func (so *SampleOptimizer) OptimizeWithCallback(callback func(generation uint, b
 
 // 辅助函数
 
-// randomSample 从 [start, end) 范围内随机采样 n 个不重复的整数
+// buildCombinations 按策略为每个类型生成 combCount 个候选子集，返回 (combCount, numCode)。
+func buildCombinations(strategy CandidateStrategy, rawDist [][]float64, quotas, splits []int, combCount int) [][]*Combination {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	allCombs := make([][]*Combination, combCount)
+	for combIdx := range allCombs {
+		allCombs[combIdx] = make([]*Combination, len(quotas))
+	}
+
+	start := 0
+	for i, quota := range quotas {
+		end := splits[i]
+		sets := generateCandidates(strategy, rawDist, start, end, quota, combCount, rng)
+		for combIdx, set := range sets {
+			allCombs[combIdx][i] = NewCombination(set)
+		}
+		start = end
+	}
+	return allCombs
+}
+
+// clampQuotas 返回按每个类型的追踪数截断后的配额副本。
+func clampQuotas(quotas, bases []int) []int {
+	clamped := make([]int, len(quotas))
+	for i, quota := range quotas {
+		if i < len(bases) && quota > bases[i] {
+			quota = bases[i]
+		}
+		if quota < 0 {
+			quota = 0
+		}
+		clamped[i] = quota
+	}
+	return clamped
+}
+
+// randomSample 从 [start, end) 范围内随机采样 n 个不重复的整数，n 超过范围大小时返回全部。
 func randomSample(start, end, n int) []int {
 	if n > end-start {
-		panic("sample size larger than population")
+		n = end - start
+	}
+	if n < 0 {
+		n = 0
 	}
 
 	population := make([]int, end-start)
@@ -529,6 +521,23 @@ func randomSample(start, end, n int) []int {
 	return population[:n]
 }
 
+// prepareOrigin 为每个特征列计算原始数据的统计量，并返回百分位数、最小值和最大值。
+func prepareOrigin(objective Objective, originT [][]float64, numLabel int) (ps []float64, originP [][]float64,
+	minV, maxV []float64, stats []columnStats) {
+	ps = objective.percentiles()
+	originP = make([][]float64, numLabel)
+	minV = make([]float64, numLabel)
+	maxV = make([]float64, numLabel)
+	stats = make([]columnStats, numLabel)
+	for i := 0; i < numLabel; i++ {
+		stats[i] = objective.prepare(i, originT[i])
+		originP[i] = stats[i].percentiles
+		minV[i] = stats[i].min
+		maxV[i] = stats[i].max
+	}
+	return ps, originP, minV, maxV, stats
+}
+
 // transpose 转置二维切片
 func transpose(matrix [][]float64) [][]float64 {
 	if len(matrix) == 0 {
@@ -549,15 +558,18 @@ func transpose(matrix [][]float64) [][]float64 {
 	return result
 }
 
-// percentile 计算百分位数
+// percentile 计算百分位数，忽略缺失值 (NaN)。没有非缺失值时返回 NaN。
 func percentile(data []float64, p float64) float64 {
-	if len(data) == 0 {
+	// 创建不含缺失值的副本并排序
+	sorted := make([]float64, 0, len(data))
+	for _, v := range data {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
 		return math.NaN()
 	}
-
-	// 创建副本并排序
-	sorted := make([]float64, len(data))
-	copy(sorted, data)
 	sort.Float64s(sorted)
 
 	if p == 0 {
@@ -581,32 +593,38 @@ func percentile(data []float64, p float64) float64 {
 	return sorted[lower]*(1-weight) + sorted[upper]*weight
 }
 
-// max 返回切片中的最大值
+// max 返回切片中的最大值，忽略缺失值。没有非缺失值时返回 NaN。
 func max(data []float64) float64 {
-	if len(data) == 0 {
-		return math.NaN()
-	}
-
-	maxVal := data[0]
-	for _, v := range data[1:] {
-		if v > maxVal {
+	maxVal := math.NaN()
+	for _, v := range data {
+		if v > maxVal || math.IsNaN(maxVal) {
 			maxVal = v
 		}
 	}
 	return maxVal
 }
 
-// minFloat 返回切片中的最小值
+// minFloat 返回切片中的最小值，忽略缺失值。没有非缺失值时返回 NaN。
 func minFloat(data []float64) float64 {
-	if len(data) == 0 {
-		return math.NaN()
-	}
-
-	minVal := data[0]
-	for _, v := range data[1:] {
-		if v < minVal {
+	minVal := math.NaN()
+	for _, v := range data {
+		if v < minVal || math.IsNaN(minVal) {
 			minVal = v
 		}
 	}
 	return minVal
-}
\ No newline at end of file
+}
+
+// presence 返回非缺失值的比例，即包含该标签的追踪占比。
+func presence(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	n := 0
+	for _, v := range data {
+		if !math.IsNaN(v) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(data))
+}
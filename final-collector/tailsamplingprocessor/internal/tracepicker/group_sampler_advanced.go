@@ -29,9 +29,13 @@ type SampleProblemAdvanced struct {
 	NumLabel int
 	Dim      int
 
+	// 一致性目标
+	Objective Objective
+	origin    []columnStats // (numLabel)
+
 	// 百分位数和标准化
-	Ps      []float64   // [0, 25, 50, 75, 90, 95, 99, 100]
-	OriginP [][]float64 // (numLabel, 8)
+	Ps      []float64   // 默认 [0, 25, 50, 75, 90, 95, 99, 100]
+	OriginP [][]float64 // (numLabel, len(Ps))，仅 percentile_rmse
 	MaxV    []float64   // (numLabel)
 	MinV    []float64   // (numLabel)
 
@@ -41,13 +45,28 @@ type SampleProblemAdvanced struct {
 
 	// 当前种群大小（用于计算）
 	Np int
+
+	// 候选子集的生成策略
+	Strategy CandidateStrategy
+
+	// Seeds 是播种候选子集使用的精英，未播种时为 nil
+	Seeds [][]Strata
 }
 
 // NewSampleProblemAdvanced 创建高级采样问题
-func NewSampleProblemAdvanced(rawDist, abDist [][]float64, quotas, bases []int, combCount int) (*SampleProblemAdvanced, error) {
+// 配额超过该类型的追踪数时按追踪数截断。
+func NewSampleProblemAdvanced(rawDist, abDist [][]float64, quotas, bases []int, combCount int,
+	strategy CandidateStrategy, objective Objective) (*SampleProblemAdvanced, error) {
 	if combCount < 2 {
 		return nil, fmt.Errorf("combCount must be larger than 2")
 	}
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := objective.Validate(); err != nil {
+		return nil, err
+	}
+	quotas = clampQuotas(quotas, bases)
 
 	numLabel := len(rawDist[0])
 	splits := make([]int, len(bases))
@@ -67,9 +86,10 @@ func NewSampleProblemAdvanced(rawDist, abDist [][]float64, quotas, bases []int,
 		C:         sumInt(quotas),
 		NumLabel:  numLabel,
 		Dim:       len(quotas),
-		Ps:        []float64{0, 25, 50, 75, 90, 95, 99, 100},
 		Lb:        make([]int, len(quotas)),
 		Ub:        make([]int, len(quotas)),
+		Strategy:  strategy,
+		Objective: objective,
 	}
 
 	// 设置上下界
@@ -93,26 +113,7 @@ func NewSampleProblemAdvanced(rawDist, abDist [][]float64, quotas, bases []int,
 func (sp *SampleProblemAdvanced) initCombinations() error {
 	fmt.Printf("[DEBUG] Initializing combinations...\n")
 
-	sp.AllCombs = make([][]*Combination, sp.CombCount)
-
-	for combIdx := 0; combIdx < sp.CombCount; combIdx++ {
-		sp.AllCombs[combIdx] = make([]*Combination, len(sp.Quotas))
-
-		start := 0
-		for codeIdx, quota := range sp.Quotas {
-			end := sp.Splits[codeIdx]
-
-			// 随机采样生成组合
-			if end-start < quota {
-				return fmt.Errorf("not enough data for code %d: need %d, have %d", codeIdx, quota, end-start)
-			}
-
-			comb := randomSampleRange(start, end, quota)
-			sp.AllCombs[combIdx][codeIdx] = NewCombination(comb)
-
-			start = end
-		}
-	}
+	sp.AllCombs = buildCombinations(sp.Strategy, sp.RawDist, sp.Quotas, sp.Splits, sp.CombCount)
 
 	fmt.Printf("[DEBUG] Initialized %d combinations for %d codes\n", sp.CombCount, len(sp.Quotas))
 	return nil
@@ -132,27 +133,7 @@ func (sp *SampleProblemAdvanced) calculateOriginPercentiles() {
 
 	// 转置数据：(numTrace, numLabel) -> (numLabel, numTrace)
 	transposed := transposeMatrix(allData)
-
-	sp.OriginP = make([][]float64, sp.NumLabel)
-	sp.MaxV = make([]float64, sp.NumLabel)
-	sp.MinV = make([]float64, sp.NumLabel)
-
-	for labelIdx, labelData := range transposed {
-		// 计算百分位数
-		sp.OriginP[labelIdx] = make([]float64, len(sp.Ps))
-		for i, p := range sp.Ps {
-			sp.OriginP[labelIdx][i] = calculatePercentile(labelData, p)
-		}
-
-		// 计算最大值和最小值
-		sp.MaxV[labelIdx] = maxFloat64(labelData)
-		sp.MinV[labelIdx] = minFloat64(labelData)
-
-		// 标准化原始百分位数
-		for i := range sp.OriginP[labelIdx] {
-			sp.OriginP[labelIdx][i] = (sp.OriginP[labelIdx][i] - sp.MinV[labelIdx]) / (sp.MaxV[labelIdx] - sp.MinV[labelIdx] + 1e-7)
-		}
-	}
+	sp.Ps, sp.OriginP, sp.MinV, sp.MaxV, sp.origin = prepareOrigin(sp.Objective, transposed, sp.NumLabel)
 
 	fmt.Printf("[DEBUG] Calculated percentiles for %d labels\n", sp.NumLabel)
 }
@@ -173,75 +154,12 @@ func (sp *SampleProblemAdvanced) GetIdxsByVar(genes []int) []int {
 
 // Consistency 计算一致性（对应Python的consistency方法）
 func (sp *SampleProblemAdvanced) Consistency(matrix [][]float64) []float64 {
-	// matrix: (Np * numLabel, C)
-	var sample [][]float64
-
-	if len(sp.AbDist) > 0 {
-		// 转置 AbDist: (numAbTrace, numLabel) -> (numLabel, numAbTrace)
-		abDistT := transposeMatrix(sp.AbDist)
-
-		// 复制 abDistT Np 次
-		tileAbDist := make([][]float64, sp.Np*sp.NumLabel)
-		for i := 0; i < sp.Np; i++ {
-			for j := 0; j < sp.NumLabel; j++ {
-				tileAbDist[i*sp.NumLabel+j] = make([]float64, len(abDistT[j]))
-				copy(tileAbDist[i*sp.NumLabel+j], abDistT[j])
-			}
-		}
-
-		// 水平拼接 matrix 和 tileAbDist
-		sample = make([][]float64, len(matrix))
-		for i := 0; i < len(matrix); i++ {
-			sample[i] = append(matrix[i], tileAbDist[i]...)
-		}
-	} else {
-		sample = matrix
-	}
-
-	// 计算采样数据的百分位数
-	sampleP := make([][]float64, len(sample))
-	for i := 0; i < len(sample); i++ {
-		sampleP[i] = make([]float64, len(sp.Ps))
-		for j, p := range sp.Ps {
-			sampleP[i][j] = calculatePercentile(sample[i], p)
-		}
-	}
-
-	// 复制原始百分位数 Np 次
-	originP := make([][]float64, sp.Np*sp.NumLabel)
-	for i := 0; i < sp.Np; i++ {
-		for j := 0; j < sp.NumLabel; j++ {
-			originP[i*sp.NumLabel+j] = make([]float64, len(sp.OriginP[j]))
-			copy(originP[i*sp.NumLabel+j], sp.OriginP[j])
-		}
-	}
-
-	// 标准化采样百分位数
-	for i := 0; i < len(sampleP); i++ {
-		labelIdx := i % sp.NumLabel
-		for j := 0; j < len(sampleP[i]); j++ {
-			sampleP[i][j] = (sampleP[i][j] - sp.MinV[labelIdx]) / (sp.MaxV[labelIdx] - sp.MinV[labelIdx] + 1e-7)
-		}
-	}
-
-	// 计算RMSE
-	result := make([]float64, sp.Np)
-	for i := 0; i < sp.Np; i++ {
-		var mseSum float64
-		for j := 0; j < sp.NumLabel; j++ {
-			idx := i*sp.NumLabel + j
-			var mse float64
-			for k := 0; k < len(sp.Ps); k++ {
-				diff := sampleP[idx][k] - originP[idx][k]
-				mse += diff * diff
-			}
-			mse /= float64(len(sp.Ps))
-			mseSum += mse
-		}
-		result[i] = mseSum
-	}
+	return sp.consistency(matrix, sp.Np)
+}
 
-	return result
+// consistency 与 Consistency 相同，但使用给定的组数 np，不读取也不修改 sp.Np。
+func (sp *SampleProblemAdvanced) consistency(matrix [][]float64, np int) []float64 {
+	return consistencyScore(matrix, np, sp.AbDist, sp.NumLabel, sp.Objective, sp.origin)
 }
 
 // EvalVars 评估变量（对应Python的evalVars方法）
@@ -364,34 +282,6 @@ func sumInt(slice []int) int {
 	return sum
 }
 
-func maxFloat64(data []float64) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	maxVal := data[0]
-	for _, v := range data[1:] {
-		if v > maxVal {
-			maxVal = v
-		}
-	}
-	return maxVal
-}
-
-func minFloat64(data []float64) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	minVal := data[0]
-	for _, v := range data[1:] {
-		if v < minVal {
-			minVal = v
-		}
-	}
-	return minVal
-}
-
 func transposeMatrix(matrix [][]float64) [][]float64 {
 	if len(matrix) == 0 {
 		return nil
@@ -411,50 +301,13 @@ func transposeMatrix(matrix [][]float64) [][]float64 {
 	return result
 }
 
-func calculatePercentile(data []float64, p float64) float64 {
-	if len(data) == 0 {
-		return 0
-	}
-
-	// 手动排序
-	sorted := make([]float64, len(data))
-	copy(sorted, data)
-
-	// 简单冒泡排序
-	for i := 0; i < len(sorted); i++ {
-		for j := 0; j < len(sorted)-1-i; j++ {
-			if sorted[j] > sorted[j+1] {
-				sorted[j], sorted[j+1] = sorted[j+1], sorted[j]
-			}
-		}
-	}
-
-	if p <= 0 {
-		return sorted[0]
-	}
-	if p >= 100 {
-		return sorted[len(sorted)-1]
-	}
-
-	index := p / 100.0 * float64(len(sorted)-1)
-	lower := int(index)
-	upper := lower + 1
-
-	if upper >= len(sorted) {
-		return sorted[lower]
-	}
-
-	if lower == upper {
-		return sorted[lower]
-	}
-
-	weight := index - float64(lower)
-	return sorted[lower]*(1-weight) + sorted[upper]*weight
-}
-
+// randomSampleRange 从 [start, end) 范围内随机采样 n 个不重复的整数，n 超过范围大小时返回全部。
 func randomSampleRange(start, end, n int) []int {
 	if n > end-start {
-		panic("sample size larger than population")
+		n = end - start
+	}
+	if n < 0 {
+		n = 0
 	}
 
 	population := make([]int, end-start)
@@ -115,6 +115,10 @@ func NewSampleVectorSimple(problem *SampleProblem) *SampleVectorSimple {
 // SampleOptimizerSimple 简化的采样优化器
 type SampleOptimizerSimple struct {
 	Problem *SampleProblem
+	// Budget 限制优化的代数和时间，零值时使用默认代数且不提前停止。
+	Budget OptimizeBudget
+	// Stats 是最近一次优化的统计信息。
+	Stats OptimizeStats
 }
 
 // NewSampleOptimizerSimple 创建简化的优化器
@@ -133,7 +137,13 @@ func (so *SampleOptimizerSimple) OptimizeSimple() (*SampleVectorSimple, error) {
 	fmt.Printf("[DEBUG] Starting simple genetic algorithm optimization...\n")
 
 	// 创建工厂函数
+	// 问题已播种时，初始种群的第一个个体是上一批次最优解的重建
+	seeded := so.Problem.Seeds == nil
 	factory := func(rng *rand.Rand) eaopt.Genome {
+		if !seeded {
+			seeded = true
+			return &SampleVectorSimple{Genes: make([]int, so.Problem.Dim), problem: so.Problem}
+		}
 		return NewSampleVectorSimple(so.Problem)
 	}
 
@@ -143,6 +153,8 @@ func (so *SampleOptimizerSimple) OptimizeSimple() (*SampleVectorSimple, error) {
 	config.PopSize = 10     // 减少种群大小
 	config.NGenerations = 5 // 减少代数
 
+	finish := applyBudget(&config, so.Budget)
+
 	fmt.Printf("[DEBUG] Creating GA with config: NPops=%d, PopSize=%d, NGenerations=%d\n",
 		config.NPops, config.PopSize, config.NGenerations)
 
@@ -158,6 +170,7 @@ func (so *SampleOptimizerSimple) OptimizeSimple() (*SampleVectorSimple, error) {
 	if err != nil {
 		return nil, fmt.Errorf("GA minimize failed: %v", err)
 	}
+	so.Stats = finish(ga)
 
 	fmt.Printf("[DEBUG] Getting best individual from HallOfFame...\n")
 
@@ -187,6 +200,7 @@ func (so *SampleOptimizerSimple) OptimizeWithSimpleFallback() (*SampleVectorSimp
 	// 尝试遗传算法优化
 	result, err := so.OptimizeSimple()
 	if err != nil {
+		so.Stats = OptimizeStats{StopReason: StopFailed}
 		fmt.Printf("[WARN] Genetic algorithm failed: %v, using fallback\n", err)
 		return fallback, nil
 	}
@@ -0,0 +1,126 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/label.go
+
+package tracepicker
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// OtherLabel 是标签数超过上限后，所有新标签归入的桶。
+const OtherLabel = "other"
+
+var (
+	numericSegment = regexp.MustCompile(`^[0-9]+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// LabelRule 将 span 名称中匹配 Pattern 的部分替换为 Template，名称的其余部分保持不变。
+// Template 使用 regexp.Expand 语法，例如 "$1 /user/{id}"。需要改写整个名称时将 Pattern 锚定为 "^...$"。
+type LabelRule struct {
+	Pattern  *regexp.Regexp
+	Template string
+}
+
+// NormalizerConfig 是 LabelNormalizer 的配置。
+type NormalizerConfig struct {
+	// NameAttributes 按顺序查找的 span 属性 (例如 http.route)，
+	// 第一个存在的属性值代替 span 名称。
+	NameAttributes []string
+	// Rules 按顺序匹配，只应用第一条匹配的规则。
+	Rules []LabelRule
+	// CollapseNumericSegments 将名称中以 "/" 分隔的纯数字段替换为 "{num}"。
+	CollapseNumericSegments bool
+	// CollapseUUIDSegments 将名称中以 "/" 分隔的 UUID 段替换为 "{uuid}"。
+	CollapseUUIDSegments bool
+	// MaxLabels 是不同标签数的上限，超过后新标签归入 OtherLabel。0 表示不限制。
+	MaxLabels int
+}
+
+// LabelNormalizer 将 span 转换为 "service:operation" 标签，并控制标签基数。
+// 编码器、HistPool 和延迟矩阵都通过同一个 LabelNormalizer 获取标签，
+// 以保证同一个 span 在各处得到相同的标签。
+type LabelNormalizer struct {
+	cfg   NormalizerConfig
+	mutex sync.RWMutex
+	known map[string]struct{} // 已分配的标签，超过上限后不再增长
+}
+
+// NewLabelNormalizer 是 LabelNormalizer 的构造函数。
+// 零值配置等价于直接使用原始的 "service:spanName" 标签。
+func NewLabelNormalizer(cfg NormalizerConfig) *LabelNormalizer {
+	return &LabelNormalizer{
+		cfg:   cfg,
+		known: make(map[string]struct{}),
+	}
+}
+
+// Label 返回 span 归一化后的标签。
+func (n *LabelNormalizer) Label(span ptrace.Span) string {
+	serviceName := "unknown.service"
+	if val, ok := span.Attributes().Get("service.name"); ok {
+		serviceName = val.Str()
+	}
+	label := serviceName + ":" + n.normalizeName(span)
+
+	if n.cfg.MaxLabels <= 0 {
+		return label
+	}
+	return n.admit(label)
+}
+
+// normalizeName 对 span 名称依次应用属性命名、改写规则和分段折叠。
+func (n *LabelNormalizer) normalizeName(span ptrace.Span) string {
+	name := span.Name()
+	for _, key := range n.cfg.NameAttributes {
+		if val, ok := span.Attributes().Get(key); ok && val.AsString() != "" {
+			name = val.AsString()
+			break
+		}
+	}
+
+	for _, rule := range n.cfg.Rules {
+		if rule.Pattern.MatchString(name) {
+			name = rule.Pattern.ReplaceAllString(name, rule.Template)
+			break
+		}
+	}
+
+	if n.cfg.CollapseNumericSegments || n.cfg.CollapseUUIDSegments {
+		segments := strings.Split(name, "/")
+		for i, seg := range segments {
+			switch {
+			case n.cfg.CollapseNumericSegments && numericSegment.MatchString(seg):
+				segments[i] = "{num}"
+			case n.cfg.CollapseUUIDSegments && uuidSegment.MatchString(seg):
+				segments[i] = "{uuid}"
+			}
+		}
+		name = strings.Join(segments, "/")
+	}
+	return name
+}
+
+// admit 登记一个标签；标签数已达上限时返回 OtherLabel。
+func (n *LabelNormalizer) admit(label string) string {
+	n.mutex.RLock()
+	_, ok := n.known[label]
+	n.mutex.RUnlock()
+	if ok {
+		return label
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if _, ok := n.known[label]; ok {
+		return label
+	}
+	if len(n.known) >= n.cfg.MaxLabels {
+		return OtherLabel
+	}
+	n.known[label] = struct{}{}
+	return label
+}
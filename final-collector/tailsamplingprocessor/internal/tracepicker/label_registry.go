@@ -0,0 +1,67 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/label_registry.go
+
+package tracepicker
+
+import (
+	"sync"
+)
+
+// LabelRegistry 为标签分配稳定的列下标，所有批次共享。
+// 标签一旦登记，下标就不再变化，不同批次的延迟矩阵和一致性误差因此可以逐列对齐、长期比较。
+// 下标不回收，因此登记数达到 max 后不再接受新标签，延迟矩阵的宽度随之有界。
+type LabelRegistry struct {
+	mutex  sync.RWMutex
+	max    int // 标签数上限，0 表示不限制
+	index  map[string]int
+	labels []string
+}
+
+// NewLabelRegistry 是 LabelRegistry 的构造函数，maxLabels 为 0 表示不限制标签数。
+func NewLabelRegistry(maxLabels int) *LabelRegistry {
+	return &LabelRegistry{max: maxLabels, index: make(map[string]int)}
+}
+
+// Register 登记一个标签并返回其下标，已登记的标签返回原下标。
+// 标签数已达上限时新标签不登记，ok 为 false，调用方不为它生成特征列。
+func (r *LabelRegistry) Register(label string) (idx int, ok bool) {
+	r.mutex.RLock()
+	idx, ok = r.index[label]
+	r.mutex.RUnlock()
+	if ok {
+		return idx, true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if idx, ok := r.index[label]; ok {
+		return idx, true
+	}
+	if r.max > 0 && len(r.labels) >= r.max {
+		return 0, false
+	}
+	idx = len(r.labels)
+	r.index[label] = idx
+	r.labels = append(r.labels, label)
+	return idx, true
+}
+
+// Snapshot 返回当前所有标签 (按下标排列) 及标签到下标的映射。
+// 之后登记的标签追加在末尾，不影响快照中已有标签的下标。
+func (r *LabelRegistry) Snapshot() (labels []string, label2idx map[string]int) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	labels = append([]string(nil), r.labels...)
+	label2idx = make(map[string]int, len(r.index))
+	for label, idx := range r.index {
+		label2idx[label] = idx
+	}
+	return labels, label2idx
+}
+
+// Len 返回已登记的标签数。
+func (r *LabelRegistry) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.labels)
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mustRegister 登记一个标签，断言登记成功并返回下标。
+func mustRegister(t *testing.T, r *LabelRegistry, label string) int {
+	t.Helper()
+	idx, ok := r.Register(label)
+	assert.True(t, ok)
+	return idx
+}
+
+func TestLabelRegistryStableIndices(t *testing.T) {
+	r := NewLabelRegistry(0)
+	assert.Equal(t, 0, mustRegister(t, r, "svc:b"))
+	assert.Equal(t, 1, mustRegister(t, r, "svc:a"))
+	assert.Equal(t, 0, mustRegister(t, r, "svc:b"))
+
+	labels, label2idx := r.Snapshot()
+	assert.Equal(t, []string{"svc:b", "svc:a"}, labels)
+	assert.Equal(t, map[string]int{"svc:b": 0, "svc:a": 1}, label2idx)
+
+	// 之后登记的标签追加在末尾，不影响已有快照
+	assert.Equal(t, 2, mustRegister(t, r, "svc:c"))
+	assert.Equal(t, 3, r.Len())
+	assert.Len(t, labels, 2)
+}
+
+func TestLabelRegistryMaxLabels(t *testing.T) {
+	r := NewLabelRegistry(2)
+	assert.Equal(t, 0, mustRegister(t, r, "svc:a"))
+	assert.Equal(t, 1, mustRegister(t, r, "svc:b"))
+
+	// 达到上限后新标签不登记，已登记的标签仍返回原下标
+	_, ok := r.Register("svc:c")
+	assert.False(t, ok)
+	assert.Equal(t, 1, mustRegister(t, r, "svc:b"))
+	assert.Equal(t, 2, r.Len())
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func newLabelSpan(service, name string, attrs map[string]string) ptrace.Span {
+	span := ptrace.NewSpan()
+	span.SetName(name)
+	span.Attributes().PutStr("service.name", service)
+	for k, v := range attrs {
+		span.Attributes().PutStr(k, v)
+	}
+	return span
+}
+
+func TestLabelNormalizerRawByDefault(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{})
+	assert.Equal(t, "frontend:GET /user/123", n.Label(newLabelSpan("frontend", "GET /user/123", nil)))
+}
+
+func TestLabelNormalizerCollapsesSegments(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{CollapseNumericSegments: true, CollapseUUIDSegments: true})
+
+	assert.Equal(t, "frontend:GET /user/{num}", n.Label(newLabelSpan("frontend", "GET /user/123", nil)))
+	assert.Equal(t, "frontend:GET /post/{uuid}/likes",
+		n.Label(newLabelSpan("frontend", "GET /post/3f2b8c1e-9a4d-4e6f-8b21-0c5d7e9f1a23/likes", nil)))
+}
+
+func TestLabelNormalizerPrefersNameAttributes(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{NameAttributes: []string{"http.route"}})
+
+	span := newLabelSpan("frontend", "GET /user/123", map[string]string{"http.route": "/user/:id"})
+	assert.Equal(t, "frontend:/user/:id", n.Label(span))
+}
+
+func TestLabelNormalizerAppliesFirstMatchingRule(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{Rules: []LabelRule{
+		{Pattern: regexp.MustCompile(`^(GET|POST) /hotels/.*$`), Template: "$1 /hotels/*"},
+		{Pattern: regexp.MustCompile(`.*`), Template: "never"},
+	}})
+
+	assert.Equal(t, "frontend:GET /hotels/*", n.Label(newLabelSpan("frontend", "GET /hotels/42/rooms", nil)))
+}
+
+func TestLabelNormalizerRulePartialMatch(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{Rules: []LabelRule{
+		{Pattern: regexp.MustCompile(`/users/\d+`), Template: "/users/{id}"},
+	}})
+
+	// 未锚定的规则只替换匹配的部分
+	assert.Equal(t, "frontend:GET /users/{id}/orders", n.Label(newLabelSpan("frontend", "GET /users/42/orders", nil)))
+	assert.Equal(t, "frontend:GET /items", n.Label(newLabelSpan("frontend", "GET /items", nil)))
+}
+
+func TestLabelNormalizerCapsLabels(t *testing.T) {
+	n := NewLabelNormalizer(NormalizerConfig{MaxLabels: 2})
+
+	assert.Equal(t, "a:x", n.Label(newLabelSpan("a", "x", nil)))
+	assert.Equal(t, "a:y", n.Label(newLabelSpan("a", "y", nil)))
+	assert.Equal(t, OtherLabel, n.Label(newLabelSpan("a", "z", nil)))
+	// 已登记的标签在达到上限后保持不变
+	assert.Equal(t, "a:x", n.Label(newLabelSpan("a", "x", nil)))
+}
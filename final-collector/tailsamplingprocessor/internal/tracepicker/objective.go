@@ -0,0 +1,315 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/objective.go
+
+package tracepicker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Distance 是一致性目标中采样分布与原始分布之间的距离。
+type Distance string
+
+const (
+	// DistancePercentileRMSE 比较标准化后的百分位数，取 (加权) 均方误差 (与早期版本一致)。
+	DistancePercentileRMSE Distance = "percentile_rmse"
+	// DistanceWasserstein 是标准化后两个经验分布之间的 Wasserstein-1 距离，
+	// 即两个累积分布函数之差的绝对值的积分，对整个分布敏感而不只是几个百分位点。
+	DistanceWasserstein Distance = "wasserstein"
+	// DistanceKS 是 Kolmogorov-Smirnov 统计量，即两个累积分布函数之差的最大绝对值。
+	DistanceKS Distance = "ks"
+)
+
+// DefaultPercentiles 是 percentile_rmse 默认比较的百分位点，对应 Python TracePicker 的 Ps。
+var DefaultPercentiles = []float64{0, 25, 50, 75, 90, 95, 99, 100}
+
+// Objective 描述遗传算法最小化的一致性目标：每个特征列的采样分布与原始分布之间的距离，
+// 按列权重求和。缺失值 (追踪中没有该标签，特征为 NaN) 不参与分布的比较，
+// 而是通过出现频率项单独计分，包含可选调用的类型因此能被正确评分。
+type Objective struct {
+	Distance Distance
+	// Percentiles 是 percentile_rmse 比较的百分位点，为空时使用 DefaultPercentiles。
+	Percentiles []float64
+	// PercentileWeights 与 Percentiles 一一对应，为空时等权。
+	PercentileWeights []float64
+	// ColumnWeights 是每个特征列的权重，为空时等权 (每列权重为 1)。
+	ColumnWeights []float64
+	// PresenceWeight 是出现频率项的权重：采样集合与原始数据中该标签出现比例之差的平方。
+	PresenceWeight float64
+	// ColumnRanges 是每个特征列的标准化范围，例如来自 HistPool 的长期历史，
+	// 使不同批次的一致性误差可以比较。为空或 Min 为 NaN 的列使用当前批次的最小值和最大值。
+	ColumnRanges []ColumnRange
+	// CumulativeWeight 是跨批次累积项的权重，为 0 时不启用。累积项比较"至今所有采样 + 本批候选"
+	// 与"至今所有数据 + 本批数据"的百分位数 (与 percentile_rmse 相同的百分位点和权重)，
+	// 使整体采样结果而不只是单个批次与原始分布一致。
+	CumulativeWeight float64
+	// Cumulative 是本批次开始时的累积数据，列与特征矩阵一一对应。
+	Cumulative *CumulativeSnapshot
+}
+
+// ColumnRange 是一个特征列标准化使用的 [Min, Max] 范围。
+type ColumnRange struct {
+	Min, Max float64
+}
+
+// DefaultPresenceWeight 是出现频率项的默认权重。
+const DefaultPresenceWeight = 1.0
+
+// DefaultObjective 返回默认目标：8 个等权百分位点的均方误差，加上出现频率项。
+func DefaultObjective() Objective {
+	return Objective{Distance: DistancePercentileRMSE, Percentiles: DefaultPercentiles, PresenceWeight: DefaultPresenceWeight}
+}
+
+// Validate 检查目标配置是否有效。
+func (o Objective) Validate() error {
+	switch o.Distance {
+	case "", DistancePercentileRMSE, DistanceWasserstein, DistanceKS:
+	default:
+		return fmt.Errorf("unknown distance %q", o.Distance)
+	}
+	for _, p := range o.Percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentile %v must be in [0, 100]", p)
+		}
+	}
+	if len(o.PercentileWeights) > 0 {
+		if len(o.PercentileWeights) != len(o.percentiles()) {
+			return fmt.Errorf("got %d percentile weights for %d percentiles", len(o.PercentileWeights), len(o.percentiles()))
+		}
+		if err := validateWeights(o.PercentileWeights); err != nil {
+			return fmt.Errorf("percentile weights: %w", err)
+		}
+	}
+	for _, w := range o.ColumnWeights {
+		if w < 0 {
+			return fmt.Errorf("column weights must not be negative")
+		}
+	}
+	if o.PresenceWeight < 0 {
+		return fmt.Errorf("presence weight must not be negative")
+	}
+	if o.CumulativeWeight < 0 {
+		return fmt.Errorf("cumulative weight must not be negative")
+	}
+	return nil
+}
+
+func validateWeights(weights []float64) error {
+	var sum float64
+	for _, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("weights must not be negative")
+		}
+		sum += w
+	}
+	if sum <= 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	return nil
+}
+
+func (o Objective) percentiles() []float64 {
+	if len(o.Percentiles) == 0 {
+		return DefaultPercentiles
+	}
+	return o.Percentiles
+}
+
+// columnWeight 返回第 j 个特征列的权重。
+func (o Objective) columnWeight(j int) float64 {
+	if j < len(o.ColumnWeights) {
+		return o.ColumnWeights[j]
+	}
+	return 1
+}
+
+// columnRange 返回第 j 个特征列的固定标准化范围，没有时 ok 为 false。
+func (o Objective) columnRange(j int) (ColumnRange, bool) {
+	if j >= len(o.ColumnRanges) {
+		return ColumnRange{}, false
+	}
+	r := o.ColumnRanges[j]
+	return r, !math.IsNaN(r.Min) && !math.IsNaN(r.Max) && r.Max >= r.Min
+}
+
+// columnStats 是一个特征列原始数据的预计算统计量，只基于包含该标签的追踪，
+// 数值均已按 [min, max] 标准化。
+type columnStats struct {
+	empty       bool      // 没有追踪包含该标签
+	min, max    float64   // 标准化范围
+	presence    float64   // 包含该标签的追踪占比
+	percentiles []float64 // percentile_rmse 的百分位数
+	sorted      []float64 // wasserstein 和 ks 使用的排序后的非 NaN 值
+
+	cumulativeSampled []weightedValue // 至今所有采样的累积数据
+	cumulativeSeen    []float64       // 至今所有数据与本批数据合并后的百分位数，没有数据时为 nil
+}
+
+// prepare 计算第 j 个特征列原始数据 (正常追踪与异常追踪) 的统计量。
+func (o Objective) prepare(j int, column []float64) columnStats {
+	stats := columnStats{min: minFloat(column), max: max(column), presence: presence(column)}
+	stats.empty = math.IsNaN(stats.min)
+	if r, ok := o.columnRange(j); ok {
+		stats.min, stats.max = r.Min, r.Max
+	}
+	switch o.Distance {
+	case DistanceWasserstein, DistanceKS:
+		stats.sorted = stats.normalizedSorted(column)
+	default:
+		ps := o.percentiles()
+		stats.percentiles = make([]float64, len(ps))
+		for i, p := range ps {
+			stats.percentiles[i] = stats.normalize(percentile(column, p))
+		}
+	}
+	if o.CumulativeWeight > 0 && o.Cumulative != nil {
+		seen, sampled := o.Cumulative.column(j)
+		stats.cumulativeSampled = sampled
+		stats.cumulativeSeen = weightedPercentiles(seen, column, o.percentiles())
+		for i, v := range stats.cumulativeSeen {
+			stats.cumulativeSeen[i] = stats.normalize(v)
+		}
+	}
+	return stats
+}
+
+func (s columnStats) normalize(v float64) float64 {
+	return (v - s.min) / (s.max - s.min + 1e-7)
+}
+
+func (s columnStats) normalizedSorted(values []float64) []float64 {
+	sorted := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, s.normalize(v))
+		}
+	}
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// distance 返回一列采样数据与原始数据之间的距离，加上出现频率项。结果不会是 NaN。
+func (o Objective) distance(sample []float64, origin columnStats) float64 {
+	d := o.valueDistance(sample, origin)
+	if o.PresenceWeight > 0 {
+		diff := presence(sample) - origin.presence
+		d += o.PresenceWeight * diff * diff
+	}
+	if o.CumulativeWeight > 0 && origin.cumulativeSeen != nil {
+		d += o.CumulativeWeight * o.cumulativeDistance(sample, origin)
+	}
+	return d
+}
+
+// cumulativeDistance 比较本批候选加入累积采样后的百分位数与累积原始数据的百分位数。
+// 至今没有采样且候选中也没有该标签时为最大距离 1。
+func (o Objective) cumulativeDistance(sample []float64, origin columnStats) float64 {
+	sampled := weightedPercentiles(origin.cumulativeSampled, sample, o.percentiles())
+	if sampled == nil {
+		return 1
+	}
+	for i, v := range sampled {
+		sampled[i] = origin.normalize(v)
+	}
+	return o.percentileError(sampled, origin.cumulativeSeen)
+}
+
+// percentileError 返回两组标准化百分位数的加权均方误差。
+func (o Objective) percentileError(sample, origin []float64) float64 {
+	var sum, weightSum float64
+	for i := range origin {
+		w := 1.0
+		if len(o.PercentileWeights) == len(origin) {
+			w = o.PercentileWeights[i]
+		}
+		diff := sample[i] - origin[i]
+		sum += w * diff * diff
+		weightSum += w
+	}
+	return sum / weightSum
+}
+
+// valueDistance 比较采样数据与原始数据中非缺失值的分布。
+// 原始数据中没有该标签时为 0，采样集合中没有该标签而原始数据中有时为最大距离 1。
+func (o Objective) valueDistance(sample []float64, origin columnStats) float64 {
+	if origin.empty {
+		return 0
+	}
+	switch o.Distance {
+	case DistanceWasserstein:
+		return wasserstein1(origin.normalizedSorted(sample), origin.sorted)
+	case DistanceKS:
+		return ksStatistic(origin.normalizedSorted(sample), origin.sorted)
+	}
+
+	if presence(sample) == 0 {
+		return 1
+	}
+	ps := o.percentiles()
+	sampled := make([]float64, len(ps))
+	for i, p := range ps {
+		sampled[i] = origin.normalize(percentile(sample, p))
+	}
+	return o.percentileError(sampled, origin.percentiles)
+}
+
+// wasserstein1 计算两个已排序样本的经验分布之间的 Wasserstein-1 距离。
+// 任一样本为空时返回 1 (标准化后的最大距离)。
+func wasserstein1(a, b []float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 1
+	}
+	var dist, prev float64
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var x float64
+		if j >= len(b) || (i < len(a) && a[i] <= b[j]) {
+			x = a[i]
+		} else {
+			x = b[j]
+		}
+		if i > 0 || j > 0 {
+			fa := float64(i) / float64(len(a))
+			fb := float64(j) / float64(len(b))
+			dist += math.Abs(fa-fb) * (x - prev)
+		}
+		for i < len(a) && a[i] == x {
+			i++
+		}
+		for j < len(b) && b[j] == x {
+			j++
+		}
+		prev = x
+	}
+	return dist
+}
+
+// ksStatistic 计算两个已排序样本的经验累积分布函数之差的最大绝对值。
+// 任一样本为空时返回 1。
+func ksStatistic(a, b []float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 1
+	}
+	var d float64
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		var x float64
+		if j >= len(b) || (i < len(a) && a[i] <= b[j]) {
+			x = a[i]
+		} else {
+			x = b[j]
+		}
+		for i < len(a) && a[i] == x {
+			i++
+		}
+		for j < len(b) && b[j] == x {
+			j++
+		}
+		diff := math.Abs(float64(i)/float64(len(a)) - float64(j)/float64(len(b)))
+		if diff > d {
+			d = diff
+		}
+	}
+	return d
+}
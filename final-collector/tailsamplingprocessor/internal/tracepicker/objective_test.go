@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWassersteinAndKS(t *testing.T) {
+	a := []float64{0, 0.5, 1}
+	assert.InDelta(t, 0, wasserstein1(a, a), 1e-9)
+	assert.InDelta(t, 0, ksStatistic(a, a), 1e-9)
+
+	// 整体平移 0.25
+	b := []float64{0.25, 0.75, 1.25}
+	assert.InDelta(t, 0.25, wasserstein1(a, b), 1e-9)
+	assert.InDelta(t, 1.0/3, ksStatistic(a, b), 1e-9)
+
+	assert.InDelta(t, 0.5, wasserstein1([]float64{0}, []float64{0, 1}), 1e-9)
+	assert.InDelta(t, 0.5, ksStatistic([]float64{0}, []float64{0, 1}), 1e-9)
+}
+
+func TestObjectivePercentileWeights(t *testing.T) {
+	column := []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 100}
+	sample := []float64{10, 20, 30, 40}
+
+	equal := DefaultObjective()
+	weighted := Objective{
+		Percentiles:       DefaultPercentiles,
+		PercentileWeights: []float64{1, 1, 1, 1, 1, 1, 1, 1},
+	}
+	assert.InDelta(t, equal.distance(sample, equal.prepare(0, column)), weighted.distance(sample, weighted.prepare(0, column)), 1e-12)
+
+	// 只看中位数
+	median := Objective{Percentiles: []float64{0, 50, 100}, PercentileWeights: []float64{0, 1, 0}}
+	origin := median.prepare(0, column)
+	assert.InDelta(t, 0, median.distance([]float64{40, 50}, origin), 1e-6)
+	assert.Greater(t, median.distance(sample, origin), 0.0)
+
+	require.Error(t, Objective{Percentiles: []float64{50}, PercentileWeights: []float64{1, 1}}.Validate())
+	require.Error(t, Objective{Percentiles: []float64{101}}.Validate())
+	require.Error(t, Objective{Distance: "l2"}.Validate())
+}
+
+func TestSampleProblemColumnWeights(t *testing.T) {
+	rows := [][]float64{{1, 10}, {2, 20}, {3, 30}, {4, 40}}
+	problem, err := NewSampleProblem(rows, nil, []int{2}, []int{4}, 2, 1, CandidateRandom,
+		Objective{Distance: DistanceWasserstein, ColumnWeights: []float64{1, 0}})
+	require.NoError(t, err)
+
+	both, err := NewSampleProblem(rows, nil, []int{2}, []int{4}, 2, 1, CandidateRandom,
+		Objective{Distance: DistanceWasserstein})
+	require.NoError(t, err)
+
+	selected := [][]int{{0, 1}}
+	assert.InDelta(t, 2*problem.EvalIdxs(selected)[0], both.EvalIdxs(selected)[0], 1e-6)
+}
+
+func TestObjectiveMissingValues(t *testing.T) {
+	nan := math.NaN()
+	// 可选调用：原始数据中一半的追踪包含该标签
+	column := []float64{10, nan, 20, nan, 30, nan, 40, nan}
+	for _, objective := range []Objective{
+		DefaultObjective(),
+		{Distance: DistanceWasserstein, PresenceWeight: DefaultPresenceWeight},
+		{Distance: DistanceKS, PresenceWeight: DefaultPresenceWeight},
+	} {
+		origin := objective.prepare(0, column)
+		assert.Equal(t, 10.0, origin.min)
+		assert.Equal(t, 40.0, origin.max)
+		assert.Equal(t, 0.5, origin.presence)
+
+		matching := objective.distance([]float64{20, nan, 30, nan, 10, nan, 40, nan}, origin)
+		allPresent := objective.distance([]float64{10, 20, 30, 40}, origin)
+		missing := objective.distance([]float64{nan, nan}, origin)
+		for _, d := range []float64{matching, allPresent, missing} {
+			assert.False(t, math.IsNaN(d), objective.Distance)
+		}
+		assert.Less(t, matching, allPresent, objective.Distance)
+		assert.Greater(t, missing, allPresent, objective.Distance)
+	}
+
+	assert.Equal(t, 25.0, percentile(column, 50))
+	assert.True(t, math.IsNaN(percentile([]float64{nan, nan}, 50)))
+}
+
+func TestObjectiveColumnRanges(t *testing.T) {
+	column := []float64{10, 20, 30, 40}
+	sample := []float64{10, 20}
+
+	// 固定范围比批次范围宽时，同样的偏差得到更小的距离
+	batch := Objective{Distance: DistanceWasserstein}
+	fixed := Objective{Distance: DistanceWasserstein, ColumnRanges: []ColumnRange{{Min: 0, Max: 100}}}
+	assert.Greater(t, batch.distance(sample, batch.prepare(0, column)), fixed.distance(sample, fixed.prepare(0, column)))
+	assert.InDelta(t, 0.1, fixed.valueDistance(sample, fixed.prepare(0, column)), 1e-6)
+
+	// NaN 范围回退到批次范围
+	fallback := Objective{Distance: DistanceWasserstein, ColumnRanges: []ColumnRange{{Min: math.NaN(), Max: math.NaN()}}}
+	assert.InDelta(t, batch.distance(sample, batch.prepare(0, column)), fallback.distance(sample, fallback.prepare(0, column)), 1e-12)
+}
+
+func TestFeatureSpecColumnRanges(t *testing.T) {
+	pool := NewHistPool(10)
+	pool.Add("svc:a", 2*time.Millisecond)
+	pool.Add("svc:a", 8*time.Millisecond)
+
+	spec := FeatureSpec{Features: []LatencyFeature{FeatureMax, FeatureCount, FeatureSelfTime}, Unit: time.Microsecond}
+	ranges := spec.ColumnRanges([]string{"svc:a", "svc:b"}, pool)
+	assert.Len(t, ranges, 6)
+	// 长期均值约 5ms、标准差约 3ms，下限截断为 0
+	assert.Zero(t, ranges[0].Min)
+	assert.InDelta(t, 14000, ranges[0].Max, 100)
+	assert.True(t, math.IsNaN(ranges[1].Min))
+	assert.Zero(t, ranges[2].Min)
+	assert.Equal(t, ranges[0].Max, ranges[2].Max)
+	for _, r := range ranges[3:] {
+		assert.True(t, math.IsNaN(r.Min))
+	}
+}
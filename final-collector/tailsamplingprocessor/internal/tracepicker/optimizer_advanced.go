@@ -10,6 +10,10 @@ import (
 // SampleOptimizerAdvanced 高级采样优化器
 type SampleOptimizerAdvanced struct {
 	Problem *SampleProblemAdvanced
+	// Budget 限制优化的代数和时间，零值时使用默认代数且不提前停止。
+	Budget OptimizeBudget
+	// Stats 是最近一次优化的统计信息。
+	Stats OptimizeStats
 }
 
 // NewSampleOptimizerAdvanced 创建高级优化器
@@ -28,7 +32,13 @@ func (so *SampleOptimizerAdvanced) OptimizeAdvanced() (*SampleVectorAdvanced, er
 	fmt.Printf("[DEBUG] Starting advanced genetic algorithm optimization...\n")
 
 	// 创建工厂函数
+	// 问题已播种时，初始种群的第一个个体是上一批次最优解的重建
+	seeded := so.Problem.Seeds == nil
 	factory := func(rng *rand.Rand) eaopt.Genome {
+		if !seeded {
+			seeded = true
+			return &SampleVectorAdvanced{Genes: make([]int, so.Problem.Dim), problem: so.Problem}
+		}
 		return NewSampleVectorAdvanced(so.Problem)
 	}
 
@@ -38,6 +48,8 @@ func (so *SampleOptimizerAdvanced) OptimizeAdvanced() (*SampleVectorAdvanced, er
 	config.PopSize = 20      // 增加种群大小以获得更好的解
 	config.NGenerations = 10 // 增加代数
 
+	finish := applyBudget(&config, so.Budget)
+
 	fmt.Printf("[DEBUG] Creating advanced GA with config: NPops=%d, PopSize=%d, NGenerations=%d\n",
 		config.NPops, config.PopSize, config.NGenerations)
 
@@ -53,6 +65,7 @@ func (so *SampleOptimizerAdvanced) OptimizeAdvanced() (*SampleVectorAdvanced, er
 	if err != nil {
 		return nil, fmt.Errorf("GA minimize failed: %v", err)
 	}
+	so.Stats = finish(ga)
 
 	fmt.Printf("[DEBUG] Getting best individual from HallOfFame...\n")
 
@@ -84,6 +97,7 @@ func (so *SampleOptimizerAdvanced) OptimizeWithAdvancedFallback() (*SampleVector
 	// 尝试遗传算法优化
 	result, err := so.OptimizeAdvanced()
 	if err != nil {
+		so.Stats = OptimizeStats{StopReason: StopFailed}
 		fmt.Printf("[WARN] Advanced genetic algorithm failed: %v, using fallback\n", err)
 		return fallback, nil
 	}
@@ -108,11 +122,16 @@ func ConvertToSampleProblemAdvanced(oldProblem *SampleProblem, combCount int) (*
 		oldProblem.Quotas,
 		oldProblem.Bases,
 		combCount,
+		oldProblem.Strategy,
+		oldProblem.Objective,
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create advanced problem: %v", err)
 	}
+	if oldProblem.Seeds != nil {
+		advancedProblem.Seed(oldProblem.Seeds)
+	}
 
 	fmt.Printf("[DEBUG] Converted problem: %d labels, %d codes, %d combinations\n",
 		advancedProblem.NumLabel, len(advancedProblem.Quotas), combCount)
@@ -0,0 +1,66 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/path_counter.go
+
+package tracepicker
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// pathCounterMinCount 是衰减后保留一个类型的最小计数，更小的类型被删除，长期不再采样的类型不会一直占用内存。
+const pathCounterMinCount = 1e-3
+
+// PathCounter 按类型 (typeID) 累计已采样的追踪数，用于配额分配的历史计数。
+// 计数按半衰期指数衰减，发布给其他副本和参与配额分配的都是近期的采样状态，旧流量不会一直占主导。
+type PathCounter struct {
+	mutex    sync.Mutex
+	halfLife time.Duration
+	last     time.Time
+	counts   map[string]float64
+}
+
+// NewPathCounter 是 PathCounter 的构造函数，halfLife 为 0 时不衰减。
+func NewPathCounter(halfLife time.Duration) *PathCounter {
+	return &PathCounter{halfLife: halfLife, counts: make(map[string]float64)}
+}
+
+// Add 将类型 typeID 的计数增加 n。
+func (c *PathCounter) Add(now time.Time, typeID string, n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decay(now)
+	c.counts[typeID] += float64(n)
+}
+
+// Snapshot 返回衰减到 now 的每个类型的计数。
+func (c *PathCounter) Snapshot(now time.Time) map[string]float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.decay(now)
+
+	result := make(map[string]float64, len(c.counts))
+	for typeID, count := range c.counts {
+		result[typeID] = count
+	}
+	return result
+}
+
+func (c *PathCounter) decay(now time.Time) {
+	if c.halfLife <= 0 {
+		return
+	}
+	if !c.last.IsZero() && now.After(c.last) {
+		factor := math.Pow(0.5, float64(now.Sub(c.last))/float64(c.halfLife))
+		for typeID, count := range c.counts {
+			if count *= factor; count < pathCounterMinCount {
+				delete(c.counts, typeID)
+			} else {
+				c.counts[typeID] = count
+			}
+		}
+	}
+	if now.After(c.last) {
+		c.last = now
+	}
+}
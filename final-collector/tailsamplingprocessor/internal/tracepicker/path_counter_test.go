@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathCounterDecay(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewPathCounter(time.Minute)
+	c.Add(now, "a", 8)
+	c.Add(now.Add(time.Minute), "b", 2)
+
+	counts := c.Snapshot(now.Add(2 * time.Minute))
+	assert.InDelta(t, 2, counts["a"], 1e-9)
+	assert.InDelta(t, 1, counts["b"], 1e-9)
+
+	// 衰减到阈值以下的类型被删除
+	assert.Empty(t, c.Snapshot(now.Add(time.Hour)))
+
+	noDecay := NewPathCounter(0)
+	noDecay.Add(now, "a", 3)
+	assert.Equal(t, map[string]float64{"a": 3}, noDecay.Snapshot(now.Add(time.Hour)))
+}
@@ -0,0 +1,152 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/payload_store.go
+
+package tracepicker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// PayloadStoreConfig 配置缓冲区中正常追踪完整数据 (payload) 的存放位置。
+// 内存中的 payload 总字节数超过 MemoryLimit 后，之后的 payload 按 OTLP proto 编码
+// 追加写入 Directory 下的段文件，批次结束时只读回被选中的追踪。
+type PayloadStoreConfig struct {
+	// MemoryLimit 是保存在内存中的 payload 总字节数上限，为 0 时全部保存在内存中。
+	MemoryLimit uint64
+	// Directory 是段文件所在的目录，为空时使用系统临时目录。
+	Directory string
+}
+
+// payloadStore 保存一个缓冲区周期 (两次 SwapAndClear 之间) 的 payload。
+// 溢出到磁盘的 payload 写入同一个段文件，批次处理完后整个段文件被删除。
+// mutex 只保护计数和段文件的分配，编码和写入段文件在锁外进行，并发的 put 可以同时写入各自的区间。
+type payloadStore struct {
+	config    PayloadStoreConfig
+	mutex     sync.Mutex
+	memBytes  uint64
+	file      *os.File
+	offset    int64
+	released  bool
+	writes    sync.WaitGroup // 正在写入段文件的 put，release 等待它们完成
+	marshaler ptrace.ProtoMarshaler
+}
+
+// payloadRef 指向 payloadStore 中的一条 payload。
+type payloadRef struct {
+	trace  ptrace.Traces // 保存在内存中时有效
+	spill  bool
+	offset int64
+	size   int
+}
+
+func newPayloadStore(config PayloadStoreConfig) *payloadStore {
+	return &payloadStore{config: config}
+}
+
+// put 保存一条 payload，size 是它的 OTLP proto 编码字节数 (未设置 MemoryLimit 时不使用)。
+// 写入段文件失败或存储已释放时 payload 保留在内存中，前者返回错误。
+func (s *payloadStore) put(td ptrace.Traces, size int) (payloadRef, error) {
+	s.mutex.Lock()
+	if s.released || s.config.MemoryLimit == 0 || s.memBytes+uint64(size) <= s.config.MemoryLimit {
+		s.memBytes += uint64(size)
+		s.mutex.Unlock()
+		return payloadRef{trace: td}, nil
+	}
+	s.mutex.Unlock()
+
+	ref, err := s.spill(td)
+	if err != nil {
+		s.mutex.Lock()
+		s.memBytes += uint64(size)
+		s.mutex.Unlock()
+		return payloadRef{trace: td}, err
+	}
+	return ref, nil
+}
+
+// spill 将 payload 写入段文件：在锁外编码，加锁分配文件区间，再在锁外写入。
+func (s *payloadStore) spill(td ptrace.Traces) (payloadRef, error) {
+	data, err := s.marshaler.MarshalTraces(td)
+	if err != nil {
+		return payloadRef{}, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	s.mutex.Lock()
+	if s.released {
+		s.mutex.Unlock()
+		return payloadRef{trace: td}, nil
+	}
+	if s.file == nil {
+		file, err := os.CreateTemp(s.config.Directory, "tailsampling-segment-*")
+		if err != nil {
+			s.mutex.Unlock()
+			return payloadRef{}, fmt.Errorf("failed to create payload segment: %w", err)
+		}
+		s.file = file
+	}
+	file, offset := s.file, s.offset
+	s.offset += int64(len(data))
+	s.writes.Add(1)
+	s.mutex.Unlock()
+
+	defer s.writes.Done()
+	if _, err := file.WriteAt(data, offset); err != nil {
+		return payloadRef{}, fmt.Errorf("failed to write payload segment: %w", err)
+	}
+	return payloadRef{spill: true, offset: offset, size: len(data)}, nil
+}
+
+// get 读回一条 payload。溢出到磁盘的 payload 每次读取都得到新的副本。
+func (s *payloadStore) get(ref payloadRef) (ptrace.Traces, error) {
+	if !ref.spill {
+		return ref.trace, nil
+	}
+	s.mutex.Lock()
+	file := s.file
+	s.mutex.Unlock()
+	if file == nil {
+		return ptrace.Traces{}, errors.New("payload segment already released")
+	}
+	data := make([]byte, ref.size)
+	if _, err := file.ReadAt(data, ref.offset); err != nil {
+		return ptrace.Traces{}, fmt.Errorf("failed to read payload segment: %w", err)
+	}
+	var unmarshaler ptrace.ProtoUnmarshaler
+	td, err := unmarshaler.UnmarshalTraces(data)
+	if err != nil {
+		return ptrace.Traces{}, fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	return td, nil
+}
+
+// release 等待正在进行的写入完成后删除段文件，之后的 put 把 payload 保留在内存中。
+func (s *payloadStore) release() error {
+	if s == nil {
+		return nil
+	}
+	s.mutex.Lock()
+	s.released = true
+	s.mutex.Unlock()
+	s.writes.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	s.file.Close()
+	s.file = nil
+	return os.Remove(name)
+}
+
+// spilledBytes 返回写入段文件的字节数。
+func (s *payloadStore) spilledBytes() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.offset
+}
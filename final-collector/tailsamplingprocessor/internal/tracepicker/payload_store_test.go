@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func payloadTrace(id byte) ptrace.Traces {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{id})
+	span.SetName("op")
+	return td
+}
+
+func TestSharedBufferSpillsPayloads(t *testing.T) {
+	dir := t.TempDir()
+	var sizer ptrace.ProtoMarshaler
+	size := sizer.TracesSize(payloadTrace(1))
+
+	// 内存上限只够一条追踪，之后的追踪溢出到段文件
+	buffer := NewSharedBuffer(BufferLimits{Traces: 10}, PayloadStoreConfig{MemoryLimit: uint64(size), Directory: dir})
+	for id := byte(1); id <= 3; id++ {
+		require.NoError(t, buffer.Add(Entry{
+			Trace:  payloadTrace(id),
+			Record: TraceRecord{Encoding: Encoding{TypeID: "a"}, TraceIDs: []pcommon.TraceID{{id}}, SpanCount: 1},
+		}))
+	}
+	batch := buffer.SwapAndClear()
+	require.Len(t, batch.Normal["a"], 3)
+	assert.Equal(t, int64(2*size), batch.SpilledBytes())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	for i, record := range batch.Normal["a"] {
+		td, err := batch.Load(record)
+		require.NoError(t, err)
+		assert.Equal(t, pcommon.TraceID{byte(i + 1)}, td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+		assert.Equal(t, []pcommon.TraceID{{byte(i + 1)}}, record.TraceIDs)
+	}
+
+	require.NoError(t, batch.Release())
+	files, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// 换出后的缓冲区使用新的存储
+	require.NoError(t, buffer.Add(Entry{Trace: payloadTrace(4), Record: TraceRecord{Encoding: Encoding{TypeID: "a"}}}))
+	assert.Equal(t, int64(0), buffer.SwapAndClear().SpilledBytes())
+}
+
+func TestSharedBufferConcurrentSpill(t *testing.T) {
+	dir := t.TempDir()
+	buffer := NewSharedBuffer(BufferLimits{}, PayloadStoreConfig{MemoryLimit: 1, Directory: dir})
+
+	// 并发写入同一个段文件，每条追踪都能从各自的区间读回
+	var wg sync.WaitGroup
+	for id := byte(1); id <= 32; id++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, buffer.Add(Entry{
+				Trace:  payloadTrace(id),
+				Record: TraceRecord{Encoding: Encoding{TypeID: "a"}, TraceIDs: []pcommon.TraceID{{id}}},
+			}))
+		}()
+	}
+	wg.Wait()
+
+	batch := buffer.SwapAndClear()
+	require.Len(t, batch.Normal["a"], 32)
+	for _, record := range batch.Normal["a"] {
+		td, err := batch.Load(record)
+		require.NoError(t, err)
+		assert.Equal(t, record.TraceIDs[0], td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+	}
+	require.NoError(t, batch.Release())
+}
+
+func TestPayloadStorePutAfterRelease(t *testing.T) {
+	dir := t.TempDir()
+	store := newPayloadStore(PayloadStoreConfig{MemoryLimit: 1, Directory: dir})
+	ref, err := store.put(payloadTrace(1), 10)
+	require.NoError(t, err)
+	assert.True(t, ref.spill)
+	require.NoError(t, store.release())
+
+	// 释放后的存储不再创建段文件，payload 保存在内存中
+	ref, err = store.put(payloadTrace(2), 10)
+	require.NoError(t, err)
+	assert.False(t, ref.spill)
+	td, err := store.get(ref)
+	require.NoError(t, err)
+	assert.Equal(t, pcommon.TraceID{2}, td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,171 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/quality.go
+
+package tracepicker
+
+import (
+	"errors"
+	"math"
+)
+
+// SamplingQuality 记录一个批次的采样质量：最终采样集合的一致性误差，
+// 以及同等规模随机采样的期望误差。
+type SamplingQuality struct {
+	SampledError  float64 // 最终采样集合的一致性误差
+	BaselineError float64 // 随机采样的期望一致性误差 (Monte-Carlo 估计)
+	Ratio         float64 // SampledError / BaselineError，小于 1 表示优于随机采样
+}
+
+// QualityEvaluator 评估一组最终采样索引相对于随机采样基线的质量。
+// SampleProblem、SampleProblemAdvanced 与 QualityScorer 都实现它，采样索引应来自同一个问题。
+type QualityEvaluator interface {
+	EvaluateQuality(selected []int, k int) SamplingQuality
+}
+
+// QualityScorer 只用于评估采样质量，不构造候选组合，
+// 适合不经过遗传算法的采样方式 (例如聚类采样)。
+type QualityScorer struct {
+	rawDist   [][]float64
+	abDist    [][]float64
+	quotas    []int
+	splits    []int
+	numLabel  int
+	objective Objective
+	origin    []columnStats
+}
+
+// NewQualityScorer 是 QualityScorer 的构造函数，参数的含义与 NewSampleProblem 相同。
+func NewQualityScorer(rawDist, abDist [][]float64, quotas, bases []int, objective Objective) (*QualityScorer, error) {
+	if len(rawDist) == 0 {
+		return nil, errors.New("rawDist must not be empty")
+	}
+	if err := objective.Validate(); err != nil {
+		return nil, err
+	}
+
+	qs := &QualityScorer{
+		rawDist:   rawDist,
+		abDist:    abDist,
+		quotas:    clampQuotas(quotas, bases),
+		splits:    make([]int, len(bases)),
+		numLabel:  len(rawDist[0]),
+		objective: objective,
+	}
+	sum := 0
+	for i, base := range bases {
+		sum += base
+		qs.splits[i] = sum
+	}
+
+	origin := rawDist
+	if len(abDist) > 0 {
+		origin = append(append([][]float64(nil), rawDist...), abDist...)
+	}
+	_, _, _, _, qs.origin = prepareOrigin(objective, transpose(origin), qs.numLabel)
+	return qs, nil
+}
+
+// EvaluateQuality 计算最终采样集合相对于随机采样基线的质量，见 SampleProblem.EvaluateQuality。
+func (qs *QualityScorer) EvaluateQuality(selected []int, k int) SamplingQuality {
+	return evaluateQuality(qs, selected, k)
+}
+
+func (qs *QualityScorer) qualityData() ([][]float64, []int, []int, int) {
+	return qs.rawDist, qs.quotas, qs.splits, qs.numLabel
+}
+
+func (qs *QualityScorer) consistency(matrix [][]float64, np int) []float64 {
+	return consistencyScore(matrix, np, qs.abDist, qs.numLabel, qs.objective, qs.origin)
+}
+
+// qualitySource 提供评估采样质量所需的数据。评估不修改问题的状态 (例如 Np)。
+type qualitySource interface {
+	qualityData() (rawDist [][]float64, quotas, splits []int, numLabel int)
+	consistency(matrix [][]float64, np int) []float64
+}
+
+func (sp *SampleProblem) qualityData() ([][]float64, []int, []int, int) {
+	return sp.RawDist, sp.Quotas, sp.Splits, sp.NumLabel
+}
+
+func (sp *SampleProblemAdvanced) qualityData() ([][]float64, []int, []int, int) {
+	return sp.RawDist, sp.Quotas, sp.Splits, sp.NumLabel
+}
+
+// EvalIdxs 计算若干组采样索引的一致性误差。
+// 每组索引都指向 RawDist 中的行，长度可以与总配额 C 不同。
+func (sp *SampleProblem) EvalIdxs(idxSets [][]int) []float64 {
+	return evalIdxs(sp, idxSets)
+}
+
+// RandomBaseline 用 Monte-Carlo 方法估计随机采样的期望一致性误差。
+// 每一轮为每个类型按配额随机抽取追踪，k 轮结果取平均。
+func (sp *SampleProblem) RandomBaseline(k int) float64 {
+	return randomBaseline(sp, k)
+}
+
+// EvaluateQuality 计算最终采样集合相对于随机采样基线的质量。
+func (sp *SampleProblem) EvaluateQuality(selected []int, k int) SamplingQuality {
+	return evaluateQuality(sp, selected, k)
+}
+
+// EvaluateQuality 计算最终采样集合相对于随机采样基线的质量，见 SampleProblem.EvaluateQuality。
+func (sp *SampleProblemAdvanced) EvaluateQuality(selected []int, k int) SamplingQuality {
+	return evaluateQuality(sp, selected, k)
+}
+
+func evalIdxs(src qualitySource, idxSets [][]int) []float64 {
+	rawDist, _, _, numLabel := src.qualityData()
+	result := make([]float64, len(idxSets))
+	for i, idxs := range idxSets {
+		// 构造采样数据 (numLabel, len(idxs))
+		sampleData := make([][]float64, numLabel)
+		for j := 0; j < numLabel; j++ {
+			sampleData[j] = make([]float64, len(idxs))
+			for k, idx := range idxs {
+				sampleData[j][k] = rawDist[idx][j]
+			}
+		}
+
+		// 逐组计算
+		result[i] = src.consistency(sampleData, 1)[0]
+	}
+
+	return result
+}
+
+func randomBaseline(src qualitySource, k int) float64 {
+	if k <= 0 {
+		return math.NaN()
+	}
+
+	_, quotas, splits, _ := src.qualityData()
+	idxSets := make([][]int, k)
+	for round := 0; round < k; round++ {
+		var idxs []int
+		start := 0
+		for i, quota := range quotas {
+			end := splits[i]
+			idxs = append(idxs, randomSample(start, end, min(quota, end-start))...)
+			start = end
+		}
+		idxSets[round] = idxs
+	}
+
+	var sum float64
+	for _, e := range evalIdxs(src, idxSets) {
+		sum += e
+	}
+	return sum / float64(k)
+}
+
+func evaluateQuality(src qualitySource, selected []int, k int) SamplingQuality {
+	q := SamplingQuality{
+		SampledError:  evalIdxs(src, [][]int{selected})[0],
+		BaselineError: randomBaseline(src, k),
+	}
+	q.Ratio = math.NaN()
+	if q.BaselineError > 0 {
+		q.Ratio = q.SampledError / q.BaselineError
+	}
+	return q
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateQuality(t *testing.T) {
+	rows := [][]float64{{1}, {2}, {3}, {4}, {100}, {200}}
+	problem, err := NewSampleProblem(rows, nil, []int{2, 1}, []int{4, 2}, 2, 1, CandidateRandom, DefaultObjective())
+	require.NoError(t, err)
+	problem.Np = 7
+
+	// 选中全部追踪时与原始分布完全一致
+	all := problem.EvaluateQuality([]int{0, 1, 2, 3, 4, 5}, 5)
+	assert.InDelta(t, 0, all.SampledError, 1e-9)
+	assert.Greater(t, all.BaselineError, 0.0)
+	assert.InDelta(t, 0, all.Ratio, 1e-9)
+
+	// 只选低延迟的追踪误差更大
+	low := problem.EvalIdxs([][]int{{0, 1, 2}})[0]
+	assert.Greater(t, low, all.SampledError)
+
+	// 评估不修改问题的状态
+	assert.Equal(t, 7, problem.Np)
+
+	advanced, err := ConvertToSampleProblemAdvanced(problem, 2)
+	require.NoError(t, err)
+	assert.InDelta(t, low, advanced.EvaluateQuality([]int{0, 1, 2}, 0).SampledError, 1e-9)
+	assert.True(t, math.IsNaN(problem.RandomBaseline(0)))
+}
+
+func TestQualityScorerMatchesSampleProblem(t *testing.T) {
+	rows := [][]float64{{1, 10}, {2, 20}, {3, 30}, {4, 40}, {100, 1}, {200, 2}}
+	abnormal := [][]float64{{50, 5}}
+	problem, err := NewSampleProblem(rows, abnormal, []int{2, 1}, []int{4, 2}, 2, 1, CandidateRandom, DefaultObjective())
+	require.NoError(t, err)
+	scorer, err := NewQualityScorer(rows, abnormal, []int{2, 1}, []int{4, 2}, DefaultObjective())
+	require.NoError(t, err)
+
+	selected := []int{0, 3, 5}
+	assert.InDelta(t, problem.EvaluateQuality(selected, 0).SampledError, scorer.EvaluateQuality(selected, 0).SampledError, 1e-12)
+	assert.False(t, math.IsNaN(scorer.EvaluateQuality(selected, 3).BaselineError))
+
+	_, err = NewQualityScorer(nil, nil, nil, nil, DefaultObjective())
+	assert.Error(t, err)
+}
@@ -0,0 +1,205 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/reservoir.go
+
+package tracepicker
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// ReservoirSampler 是流式采样模式的核心：每个类型一个加权蓄水池 (Efraimidis-Spirakis A-Res)，
+// 容量为该类型每个释放周期的预算。追踪的权重为 1 + 耗时/该类型的平均耗时，
+// 因此慢追踪更容易留下。Release 周期性地取出所有蓄水池中的追踪。
+// 蓄水池容量在一个释放周期内不变，SetBudgets 设置的预算从下一个释放周期开始生效。
+type ReservoirSampler struct {
+	mu             sync.Mutex
+	rng            *rand.Rand
+	defaultBudget  int
+	budgets        map[string]int
+	pendingBudgets map[string]int // SetBudgets 设置、下一次 Release 时生效的预算
+	budgeted       bool
+	reservoirs     map[string]*reservoir
+	thresholds     map[string]float64 // 当前释放周期内每个类型被挤出或拒绝的最大 key
+	arrivals       map[string]int     // 上次 Arrivals 以来每个类型到达的追踪数
+	arrivalsSince  time.Time
+	meanDuration   map[string]typeDuration
+	epoch          uint64 // Release 的次数
+}
+
+// typeDuration 是一个类型耗时的指数移动平均，以及该类型最后一次到达时的释放周期。
+type typeDuration struct {
+	mean  float64
+	epoch uint64
+}
+
+// reservoirIdleReleases 是一个类型连续多少个释放周期没有到达后删除它的耗时统计。
+const reservoirIdleReleases = 60
+
+// ReleasedTrace 是从蓄水池中释放的追踪。
+type ReleasedTrace struct {
+	TypeID string
+	Trace  ptrace.Traces
+	// Probability 是这条追踪的包含概率。蓄水池满过时为 1 - τ^w，
+	// τ 是本释放周期内该类型被挤出或拒绝的最大 key，w 是追踪的权重；
+	// 没有追踪被挤出时为 1。按 1/Probability 加权可以无偏地还原到达数。
+	Probability float64
+}
+
+// NewReservoirSampler 是 ReservoirSampler 的构造函数。
+// defaultBudget 是尚未分配预算的类型 (例如新类型) 每个释放周期的容量。
+func NewReservoirSampler(defaultBudget int, seed int64) *ReservoirSampler {
+	if defaultBudget < 1 {
+		defaultBudget = 1
+	}
+	return &ReservoirSampler{
+		rng:           rand.New(rand.NewSource(seed)),
+		defaultBudget: defaultBudget,
+		budgets:       make(map[string]int),
+		reservoirs:    make(map[string]*reservoir),
+		thresholds:    make(map[string]float64),
+		arrivals:      make(map[string]int),
+		arrivalsSince: time.Now(),
+		meanDuration:  make(map[string]typeDuration),
+	}
+}
+
+// Offer 将一条追踪放入其类型的蓄水池。
+// 蓄水池已满时，权重键最小的追踪被挤出 (可能是刚放入的这条)，作为 evicted 返回。
+func (s *ReservoirSampler) Offer(typeID string, trace ptrace.Traces, durationMs float64) (evicted ptrace.Traces, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.arrivals[typeID]++
+
+	stat, seen := s.meanDuration[typeID]
+	mean := stat.mean
+	if !seen {
+		mean = durationMs
+	}
+	weight := 1.0
+	if mean > 0 {
+		weight += durationMs / mean
+	}
+	s.meanDuration[typeID] = typeDuration{mean: 0.9*mean + 0.1*durationMs, epoch: s.epoch}
+
+	r, exists := s.reservoirs[typeID]
+	if !exists {
+		r = &reservoir{}
+		s.reservoirs[typeID] = r
+	}
+	budget := s.budget(typeID)
+	if budget <= 0 {
+		return trace, true
+	}
+
+	// A-Res: key = u^(1/w)，保留 key 最大的 budget 条
+	key := math.Pow(s.rng.Float64(), 1/weight)
+	item := reservoirItem{trace: trace, key: key, weight: weight}
+	if r.Len() < budget {
+		heap.Push(r, item)
+		return ptrace.Traces{}, false
+	}
+	if key <= (*r)[0].key {
+		s.thresholds[typeID] = math.Max(s.thresholds[typeID], key)
+		return trace, true
+	}
+	evicted = (*r)[0].trace
+	s.thresholds[typeID] = math.Max(s.thresholds[typeID], (*r)[0].key)
+	(*r)[0] = item
+	heap.Fix(r, 0)
+	return evicted, true
+}
+
+// Release 取出所有蓄水池中的追踪并开始新的释放周期。
+// 新的释放周期使用 SetBudgets 设置的预算，连续 reservoirIdleReleases 个周期没有到达的类型被删除。
+func (s *ReservoirSampler) Release() []ReleasedTrace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var released []ReleasedTrace
+	for typeID, r := range s.reservoirs {
+		threshold := s.thresholds[typeID]
+		for _, item := range *r {
+			p := 1.0
+			if threshold > 0 {
+				p = 1 - math.Pow(threshold, item.weight)
+			}
+			released = append(released, ReleasedTrace{TypeID: typeID, Trace: item.trace, Probability: p})
+		}
+	}
+	s.reservoirs = make(map[string]*reservoir)
+	s.thresholds = make(map[string]float64)
+	if s.pendingBudgets != nil {
+		s.budgets = s.pendingBudgets
+		s.pendingBudgets = nil
+	}
+
+	s.epoch++
+	for typeID, stat := range s.meanDuration {
+		if s.epoch-stat.epoch > reservoirIdleReleases {
+			delete(s.meanDuration, typeID)
+		}
+	}
+	return released
+}
+
+// Arrivals 返回上次调用以来每个类型到达的追踪数及经过的时间，并清零，用于重新计算预算。
+func (s *ReservoirSampler) Arrivals() (map[string]int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	arrivals, elapsed := s.arrivals, now.Sub(s.arrivalsSince)
+	s.arrivals = make(map[string]int)
+	s.arrivalsSince = now
+	return arrivals, elapsed
+}
+
+// SetBudgets 设置每个类型每个释放周期的预算，从下一次 Release 开始生效。未列出的类型使用 defaultBudget。
+func (s *ReservoirSampler) SetBudgets(budgets map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pendingBudgets = budgets
+	s.budgeted = true
+}
+
+// Budgeted 返回是否设置过预算。
+func (s *ReservoirSampler) Budgeted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.budgeted
+}
+
+func (s *ReservoirSampler) budget(typeID string) int {
+	if b, ok := s.budgets[typeID]; ok {
+		return b
+	}
+	return s.defaultBudget
+}
+
+type reservoirItem struct {
+	trace  ptrace.Traces
+	key    float64
+	weight float64
+}
+
+// reservoir 是按 key 排序的最小堆，堆顶是最先被挤出的追踪。
+type reservoir []reservoirItem
+
+func (r reservoir) Len() int           { return len(r) }
+func (r reservoir) Less(i, j int) bool { return r[i].key < r[j].key }
+func (r reservoir) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func (r *reservoir) Push(x any) { *r = append(*r, x.(reservoirItem)) }
+
+func (r *reservoir) Pop() any {
+	old := *r
+	item := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestReservoirSamplerRespectsBudgets(t *testing.T) {
+	s := NewReservoirSampler(1, 1)
+	s.SetBudgets(map[string]int{"a": 3})
+	s.Release()
+
+	evictedCount := 0
+	for i := 0; i < 10; i++ {
+		if _, ok := s.Offer("a", ptrace.NewTraces(), 10); ok {
+			evictedCount++
+		}
+		if _, ok := s.Offer("b", ptrace.NewTraces(), 10); ok {
+			evictedCount++
+		}
+	}
+	assert.Equal(t, 20-3-1, evictedCount)
+
+	released := s.Release()
+	perType := make(map[string]int)
+	for _, r := range released {
+		perType[r.TypeID]++
+		assert.Greater(t, r.Probability, 0.0)
+		assert.Less(t, r.Probability, 1.0)
+	}
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, perType)
+	assert.Empty(t, s.Release())
+	arrivals, _ := s.Arrivals()
+	assert.Equal(t, map[string]int{"a": 10, "b": 10}, arrivals)
+}
+
+func TestReservoirSamplerBudgetsApplyNextRelease(t *testing.T) {
+	s := NewReservoirSampler(1, 1)
+	assert.False(t, s.Budgeted())
+	s.SetBudgets(map[string]int{"a": 3})
+	assert.True(t, s.Budgeted())
+
+	for i := 0; i < 5; i++ {
+		s.Offer("a", ptrace.NewTraces(), 10)
+	}
+	assert.Len(t, s.Release(), 1)
+
+	for i := 0; i < 5; i++ {
+		s.Offer("a", ptrace.NewTraces(), 10)
+	}
+	assert.Len(t, s.Release(), 3)
+}
+
+func TestReservoirSamplerUnbiasedCounts(t *testing.T) {
+	// 按 1/Probability 加权的保留数是到达数的无偏估计，慢追踪的权重更高也不例外
+	const rounds, offered = 2000, 20
+	var sum float64
+	for seed := int64(0); seed < rounds; seed++ {
+		s := NewReservoirSampler(4, seed)
+		for i := 0; i < offered; i++ {
+			s.Offer("a", ptrace.NewTraces(), float64(10+10*(i%5)))
+		}
+		for _, r := range s.Release() {
+			sum += 1 / r.Probability
+		}
+	}
+	assert.InDelta(t, offered, sum/rounds, offered*0.05)
+}
+
+func TestReservoirSamplerPrunesIdleTypes(t *testing.T) {
+	s := NewReservoirSampler(1, 1)
+	s.Offer("a", ptrace.NewTraces(), 10)
+	for i := 0; i < reservoirIdleReleases; i++ {
+		s.Release()
+		assert.Empty(t, s.reservoirs)
+	}
+	assert.Contains(t, s.meanDuration, "a")
+	s.Release()
+	assert.Empty(t, s.meanDuration)
+}
+
+func TestReservoirSamplerPrefersSlowTraces(t *testing.T) {
+	slowKept := 0
+	for seed := int64(0); seed < 200; seed++ {
+		s := NewReservoirSampler(1, seed)
+		s.Offer("a", ptrace.NewTraces(), 10)
+		slow := ptrace.NewTraces()
+		slow.ResourceSpans().AppendEmpty()
+		s.Offer("a", slow, 1000)
+		if s.Release()[0].Trace.ResourceSpans().Len() == 1 {
+			slowKept++
+		}
+	}
+	assert.Greater(t, slowKept, 120)
+}
@@ -0,0 +1,98 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/threshold.go
+
+package tracepicker
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OpenTelemetry 一致性概率采样 (https://opentelemetry.io/docs/specs/otel/trace/tracestate-probability-sampling/)
+// 在 W3C tracestate 的 "ot" 条目中用 "th:<hex>" 记录拒绝阈值 T：
+// 56 位随机数 R >= T 的追踪被保留，采样概率 p = 1 - T/2^56。
+// th:0 表示 100% 采样。
+
+const (
+	thresholdBits   = 56
+	thresholdDigits = thresholdBits / 4
+	maxThreshold    = uint64(1) << thresholdBits
+	// thresholdPrecisionBits 是编码阈值时保留的高位数。float64 只有 53 位有效数字，
+	// 保留 48 位 (12 位十六进制) 避免把浮点误差写进最后几位。
+	thresholdPrecisionBits = 48
+)
+
+// ThresholdFromProbability 将采样概率编码为 th 值 (最多 12 位有效的十六进制数字，去掉末尾的 0)。
+// p >= 1 时返回 "0"，p <= 0 时返回 ""，表示无法编码。
+func ThresholdFromProbability(p float64) string {
+	if !(p > 0) {
+		return ""
+	}
+	if p >= 1 {
+		return "0"
+	}
+	const unit = uint64(1) << (thresholdBits - thresholdPrecisionBits)
+	t := uint64(math.Round((1-p)*float64(uint64(1)<<thresholdPrecisionBits))) * unit
+	if t >= maxThreshold {
+		t = maxThreshold - unit // 最小可编码概率
+	}
+	if t == 0 {
+		return "0"
+	}
+	s := strconv.FormatUint(t, 16)
+	s = strings.Repeat("0", thresholdDigits-len(s)) + s
+	return strings.TrimRight(s, "0")
+}
+
+// ProbabilityFromThreshold 解析 th 值并返回对应的采样概率。
+func ProbabilityFromThreshold(th string) (float64, bool) {
+	if th == "" || len(th) > thresholdDigits {
+		return 0, false
+	}
+	t, err := strconv.ParseUint(th+strings.Repeat("0", thresholdDigits-len(th)), 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return 1 - float64(t)/float64(maxThreshold), true
+}
+
+// UpdateTraceState 在 W3C tracestate 中写入采样概率 p 对应的 ot=th 阈值。
+// 已有的上游阈值 T0 按一致性采样的规则与本处理器的阈值 T 合并，写入 max(T0, T)，
+// 即两者中较小的采样概率。
+// ot 条目中的其他子键 (例如 rv) 保持不变，ot 条目按 W3C 规范移到最前。
+func UpdateTraceState(raw string, p float64) string {
+	var otFields []string
+	var others []string
+	for _, member := range strings.Split(raw, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		if value, ok := strings.CutPrefix(member, "ot="); ok {
+			otFields = strings.Split(value, ";")
+			continue
+		}
+		others = append(others, member)
+	}
+
+	fields := make([]string, 0, len(otFields)+1)
+	for _, field := range otFields {
+		if th, ok := strings.CutPrefix(field, "th:"); ok {
+			if p0, ok := ProbabilityFromThreshold(th); ok {
+				p = math.Min(p, p0)
+			}
+			continue
+		}
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	if th := ThresholdFromProbability(p); th != "" {
+		fields = append([]string{"th:" + th}, fields...)
+	}
+	if len(fields) == 0 {
+		return strings.Join(others, ",")
+	}
+	return strings.Join(append([]string{"ot=" + strings.Join(fields, ";")}, others...), ",")
+}
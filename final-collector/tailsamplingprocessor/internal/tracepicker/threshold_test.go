@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdFromProbability(t *testing.T) {
+	// 规范中的示例
+	assert.Equal(t, "0", ThresholdFromProbability(1))
+	assert.Equal(t, "8", ThresholdFromProbability(0.5))
+	assert.Equal(t, "c", ThresholdFromProbability(0.25))
+	assert.Equal(t, "e66666666666", ThresholdFromProbability(0.1))
+	assert.Equal(t, "", ThresholdFromProbability(0))
+}
+
+func TestProbabilityFromThreshold(t *testing.T) {
+	p, ok := ProbabilityFromThreshold("c")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.25, p, 1e-12)
+
+	_, ok = ProbabilityFromThreshold("xyz")
+	assert.False(t, ok)
+}
+
+func TestUpdateTraceState(t *testing.T) {
+	assert.Equal(t, "ot=th:0", UpdateTraceState("", 1))
+	assert.Equal(t, "ot=th:8,vendor=x", UpdateTraceState("vendor=x", 0.5))
+	// 上游阈值与本处理器的阈值取较大者
+	assert.Equal(t, "ot=th:8;rv:abcdef01234567,vendor=x", UpdateTraceState("vendor=x,ot=th:8;rv:abcdef01234567", 0.5))
+	assert.Equal(t, "ot=th:c;rv:abcdef01234567,vendor=x", UpdateTraceState("vendor=x,ot=th:8;rv:abcdef01234567", 0.25))
+	assert.Equal(t, "ot=th:c,vendor=x", UpdateTraceState("vendor=x,ot=th:c", 1))
+}
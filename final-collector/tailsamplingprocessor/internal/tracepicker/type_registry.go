@@ -0,0 +1,57 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/type_registry.go
+
+package tracepicker
+
+import (
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// DefaultMaxTypes 是 TypeRegistry 默认记录的类型数上限。
+const DefaultMaxTypes = 10000
+
+// TypeRegistry 记录每个追踪类型 (typeID) 出现过的次数，用于识别新出现或罕见的类型。
+// 部署后出现的新代码路径、新的错误处理分支都会产生从未见过的类型。
+// 记录的类型数有上限，超过后淘汰最久没有出现的类型，被淘汰的类型再次出现时重新视为新类型。
+type TypeRegistry struct {
+	keepFirst       uint64  // 每个类型的前 keepFirst 次出现视为新类型
+	rarityThreshold float64 // 出现比例低于该阈值的类型视为罕见类型，0 表示不判断
+	mutex           sync.Mutex
+	counts          *lru.Cache[string, uint64]
+	total           uint64
+}
+
+// NewTypeRegistry 是 TypeRegistry 的构造函数，maxTypes 为 0 时使用 DefaultMaxTypes。
+func NewTypeRegistry(keepFirst uint64, rarityThreshold float64, maxTypes int) *TypeRegistry {
+	if maxTypes <= 0 {
+		maxTypes = DefaultMaxTypes
+	}
+	counts, _ := lru.New[string, uint64](maxTypes)
+	return &TypeRegistry{
+		keepFirst:       keepFirst,
+		rarityThreshold: rarityThreshold,
+		counts:          counts,
+	}
+}
+
+// Observe 记录一次 typeID 的出现，并返回该追踪是否属于新类型或罕见类型。
+func (r *TypeRegistry) Observe(typeID string) (novel bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count, _ := r.counts.Get(typeID)
+	count++
+	r.counts.Add(typeID, count)
+	r.total++
+
+	if count <= r.keepFirst {
+		return true
+	}
+	return r.rarityThreshold > 0 && float64(count)/float64(r.total) < r.rarityThreshold
+}
+
+// Len 返回当前记录的类型数。
+func (r *TypeRegistry) Len() int {
+	return r.counts.Len()
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeRegistryObserve(t *testing.T) {
+	r := NewTypeRegistry(2, 0, 0)
+	assert.True(t, r.Observe("a"))
+	assert.True(t, r.Observe("a"))
+	assert.False(t, r.Observe("a"))
+	assert.True(t, r.Observe("b"))
+}
+
+func TestTypeRegistryRarity(t *testing.T) {
+	r := NewTypeRegistry(0, 0.2, 0)
+	for i := 0; i < 9; i++ {
+		r.Observe("common")
+	}
+	// 1/10 < 0.2
+	assert.True(t, r.Observe("rare"))
+	assert.False(t, r.Observe("common"))
+}
+
+func TestTypeRegistryEvictsLeastRecentlySeen(t *testing.T) {
+	r := NewTypeRegistry(1, 0, 2)
+	assert.True(t, r.Observe("a"))
+	assert.True(t, r.Observe("b"))
+	assert.False(t, r.Observe("a"))
+	assert.True(t, r.Observe("c")) // 淘汰 b
+	assert.Equal(t, 2, r.Len())
+
+	assert.False(t, r.Observe("a"))
+	assert.True(t, r.Observe("b"), "evicted type is novel again")
+}
@@ -0,0 +1,174 @@
+// file: processor/tailsamplingprocessor/internal/tracepicker/warm_start.go
+
+package tracepicker
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Strata 描述一个类型的一组精英采样覆盖了哪些延迟层次：被选中的每条追踪在该类型
+// 按总延迟排序后的分位位置 ([0, 1]，升序)。分位位置与批次大小无关，可以在下一批次中重建。
+type Strata []float64
+
+// EliteArchive 按类型签名 (typeID) 保存最近几个批次遗传算法最优解的 Strata。
+// 相邻批次的类型组成和延迟形状相近，下一批次的候选子集和初始种群从中播种，
+// 减少达到相同适应度所需的代数。连续 eliteIdleBatches 个批次没有出现的类型被删除。
+type EliteArchive struct {
+	mutex    sync.Mutex
+	size     int
+	elites   map[string][]Strata // 最新的在前
+	lastSeen map[string]uint64   // 每个类型最近一次出现的批次序号
+	batch    uint64              // 已记录的批次数
+}
+
+// eliteIdleBatches 是一个类型连续多少个批次没有出现后删除它的精英。
+const eliteIdleBatches = 60
+
+// NewEliteArchive 是 EliteArchive 的构造函数，size 是每个类型保存的精英数。
+func NewEliteArchive(size int) *EliteArchive {
+	return &EliteArchive{size: size, elites: make(map[string][]Strata), lastSeen: make(map[string]uint64)}
+}
+
+// Record 记录一个批次的最优解。types 与 splits 一一对应，第 i 个类型的追踪下标为
+// [splits[i-1], splits[i])，selected 是最优解选中的追踪下标。
+func (a *EliteArchive) Record(types []string, rawDist [][]float64, splits, selected []int) {
+	perType := make([][]int, len(types))
+	for _, idx := range selected {
+		i := sort.SearchInts(splits, idx+1)
+		if i < len(types) {
+			perType[i] = append(perType[i], idx)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.batch++
+	start := 0
+	for i, typeID := range types {
+		a.lastSeen[typeID] = a.batch
+		end := splits[i]
+		if len(perType[i]) > 0 && end-start > 1 {
+			strata := strataOf(traceScores(rawDist[start:end]), start, perType[i])
+			elites := append([]Strata{strata}, a.elites[typeID]...)
+			if len(elites) > a.size {
+				elites = elites[:a.size]
+			}
+			a.elites[typeID] = elites
+		}
+		start = end
+	}
+	for typeID, seen := range a.lastSeen {
+		if a.batch-seen > eliteIdleBatches {
+			delete(a.lastSeen, typeID)
+			delete(a.elites, typeID)
+		}
+	}
+}
+
+// Seeds 返回每个类型保存的精英，与 types 一一对应，没有记录的类型为 nil。
+func (a *EliteArchive) Seeds(types []string) [][]Strata {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	seeds := make([][]Strata, len(types))
+	for i, typeID := range types {
+		seeds[i] = a.elites[typeID]
+	}
+	return seeds
+}
+
+// strataOf 返回 selected (全局下标，偏移为 start) 在 scores 排序中的分位位置。
+func strataOf(scores []float64, start int, selected []int) Strata {
+	order := sortedOrder(scores)
+	rank := make([]int, len(order))
+	for r, i := range order {
+		rank[i] = r
+	}
+	strata := make(Strata, len(selected))
+	for k, idx := range selected {
+		strata[k] = float64(rank[idx-start]) / float64(len(order)-1)
+	}
+	sort.Float64s(strata)
+	return strata
+}
+
+// fromStrata 在 scores 上重建一组 quota 条追踪的子集 (局部下标)：
+// strata 重采样为 quota 个分位位置，每个位置取排序后最近的未被选中的追踪。
+func fromStrata(scores []float64, quota int, strata Strata) []int {
+	order := sortedOrder(scores)
+	n := len(order)
+	used := make([]bool, n)
+	set := make([]int, 0, quota)
+	for k := 0; k < quota && k < n; k++ {
+		q := strata[(2*k+1)*len(strata)/(2*quota)]
+		target := int(math.Round(q * float64(n-1)))
+		for d := 0; d < n; d++ {
+			if r := target - d; r >= 0 && !used[r] {
+				target = r
+				break
+			}
+			if r := target + d; r < n && !used[r] {
+				target = r
+				break
+			}
+		}
+		used[target] = true
+		set = append(set, order[target])
+	}
+	return set
+}
+
+// sortedOrder 返回按 scores 升序排列的下标。
+func sortedOrder(scores []float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+	return order
+}
+
+// seedCombinations 用精英重建的子集替换每个类型前几个候选子集，至少保留一个原有候选。
+// 返回是否有类型被播种。播种的候选位于下标 0，全 0 的基因即为上一批次最优解的重建。
+func seedCombinations(allCombs [][]*Combination, rawDist [][]float64, quotas, splits []int, seeds [][]Strata) bool {
+	seeded := false
+	start := 0
+	for i, quota := range quotas {
+		end := splits[i]
+		if i < len(seeds) && quota > 0 && end-start > quota {
+			scores := traceScores(rawDist[start:end])
+			for k, strata := range seeds[i] {
+				if k >= len(allCombs)-1 || len(strata) == 0 {
+					break
+				}
+				local := fromStrata(scores, quota, strata)
+				set := make([]int, len(local))
+				for j, idx := range local {
+					set[j] = start + idx
+				}
+				sort.Ints(set)
+				allCombs[k][i] = NewCombination(set)
+				seeded = true
+			}
+		}
+		start = end
+	}
+	return seeded
+}
+
+// Seed 用精英播种候选子集，seeds 与类型一一对应 (见 EliteArchive.Seeds)。
+// 播种后优化器的初始种群包含全 0 的基因。
+func (sp *SampleProblem) Seed(seeds [][]Strata) {
+	if seedCombinations(sp.AllCombs, sp.RawDist, sp.Quotas, sp.Splits, seeds) {
+		sp.Seeds = seeds
+	}
+}
+
+// Seed 用精英播种候选子集，见 SampleProblem.Seed。
+func (sp *SampleProblemAdvanced) Seed(seeds [][]Strata) {
+	if seedCombinations(sp.AllCombs, sp.RawDist, sp.Quotas, sp.Splits, seeds) {
+		sp.Seeds = seeds
+	}
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tracepicker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrataRoundTrip(t *testing.T) {
+	scores := []float64{50, 10, 40, 20, 30}
+	// 选中最小和最大的两条
+	strata := strataOf(scores, 10, []int{11, 10})
+	assert.Equal(t, Strata{0, 1}, strata)
+	assert.ElementsMatch(t, []int{1, 0}, fromStrata(scores, 2, strata))
+
+	// 配额变化时按分位位置重采样，不会重复选择
+	set := fromStrata(scores, 3, Strata{0.5})
+	assert.ElementsMatch(t, []int{4, 3, 2}, set)
+}
+
+func TestEliteArchiveSeedsProblem(t *testing.T) {
+	var rawDist [][]float64
+	for i := 0; i < 10; i++ {
+		rawDist = append(rawDist, []float64{float64(i)})
+	}
+	for i := 0; i < 6; i++ {
+		rawDist = append(rawDist, []float64{float64(100 + i)})
+	}
+	types := []string{"a", "b"}
+	bases := []int{10, 6}
+
+	archive := NewEliteArchive(2)
+	assert.Equal(t, [][]Strata{nil, nil}, archive.Seeds(types))
+
+	// 类型 a 的最优解是最慢的两条，类型 b 是最快的一条
+	archive.Record(types, rawDist, []int{10, 16}, []int{8, 9, 10})
+	seeds := archive.Seeds([]string{"b", "c", "a"})
+	assert.Equal(t, []Strata{{0}}, seeds[0])
+	assert.Nil(t, seeds[1])
+	assert.Equal(t, []Strata{{8.0 / 9, 1}}, seeds[2])
+
+	problem, err := NewSampleProblem(rawDist, nil, []int{2, 1}, bases, 4, 1, CandidateRandom, DefaultObjective())
+	require.NoError(t, err)
+	problem.Seed(archive.Seeds(types))
+	require.NotNil(t, problem.Seeds)
+	assert.Equal(t, []int{8, 9, 10}, problem.GetIdxsByVar([]int{0, 0}))
+
+	// 同一类型最多保存 size 个精英，最新的在前
+	archive.Record(types, rawDist, []int{10, 16}, []int{0, 1})
+	archive.Record(types, rawDist, []int{10, 16}, []int{4, 5})
+	assert.Len(t, archive.Seeds(types)[0], 2)
+	assert.Equal(t, Strata{4.0 / 9, 5.0 / 9}, archive.Seeds(types)[0][0])
+}
+
+func TestEliteArchiveEvictsIdleTypes(t *testing.T) {
+	rawDist := [][]float64{{0}, {1}, {2}, {3}}
+	archive := NewEliteArchive(1)
+	archive.Record([]string{"a", "b"}, rawDist, []int{2, 4}, []int{0, 2})
+
+	// 类型 b 一直出现，a 在 eliteIdleBatches 个批次后被删除
+	for i := 0; i < eliteIdleBatches; i++ {
+		archive.Record([]string{"b"}, rawDist[2:], []int{2}, []int{0})
+	}
+	assert.NotNil(t, archive.Seeds([]string{"a"})[0])
+	archive.Record([]string{"b"}, rawDist[2:], []int{2}, []int{0})
+	seeds := archive.Seeds([]string{"a", "b"})
+	assert.Nil(t, seeds[0])
+	assert.NotNil(t, seeds[1])
+	assert.Len(t, archive.lastSeen, 1)
+}
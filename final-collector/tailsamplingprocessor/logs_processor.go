@@ -0,0 +1,203 @@
+// file: processor/tailsamplingprocessor/logs_processor.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor"
+	"go.uber.org/zap"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/decisions"
+)
+
+// logsSamplingProcessor 是追踪采样的日志对应物：它按追踪处理器写入共享决策存储的结果过滤日志，
+// 使后端保留的日志与保留的追踪一致。
+// 带追踪 ID 的日志等到所属追踪都有决策，最多等待 Wait，之后保留所属追踪被采样的日志，
+// 其余日志按 UnsampledRatio 降采样。不带追踪 ID 的日志直接转发。
+type logsSamplingProcessor struct {
+	logger *zap.Logger
+	next   consumer.Logs
+	cfg    LogsCfg
+	store  *decisions.Store
+
+	mu             sync.Mutex
+	pending        []pendingLogs // 按到达时间排序
+	pendingRecords int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// pendingLogs 是一批等待采样决策的日志。
+type pendingLogs struct {
+	logs    plog.Logs
+	arrival time.Time
+}
+
+func newLogsProcessor(set processor.Settings, next consumer.Logs, cfg Config) (processor.Logs, error) {
+	return &logsSamplingProcessor{
+		logger: set.Logger,
+		next:   next,
+		cfg:    cfg.Logs,
+		store:  decisions.Subscribe(cfg.Decisions.Name, cfg.Decisions.TTL, cfg.Decisions.MaxEntries),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// ConsumeLogs 在所有日志所属追踪都已有决策时立即过滤并转发，否则缓存等待决策。
+func (lsp *logsSamplingProcessor) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	if lsp.decided(ld) {
+		return lsp.forward(ctx, ld)
+	}
+
+	lsp.mu.Lock()
+	lsp.pending = append(lsp.pending, pendingLogs{logs: ld, arrival: time.Now()})
+	lsp.pendingRecords += ld.LogRecordCount()
+	var overflow []pendingLogs
+	for lsp.cfg.MaxPendingRecords > 0 && lsp.pendingRecords > lsp.cfg.MaxPendingRecords && len(lsp.pending) > 0 {
+		overflow = append(overflow, lsp.pending[0])
+		lsp.pendingRecords -= lsp.pending[0].logs.LogRecordCount()
+		lsp.pending = lsp.pending[1:]
+	}
+	lsp.mu.Unlock()
+
+	if len(overflow) > 0 {
+		lsp.logger.Debug("Pending log records over limit, releasing oldest logs before decision wait",
+			zap.Int("batches", len(overflow)))
+	}
+	lsp.release(ctx, overflow)
+	return nil
+}
+
+// decided 检查日志中的每条记录是否不带追踪 ID 或其追踪已有采样决策。
+func (lsp *logsSamplingProcessor) decided(ld plog.Logs) bool {
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				traceID := lrs.At(k).TraceID()
+				if !traceID.IsEmpty() && lsp.store.Lookup(traceID) == decisions.Pending {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// forward 删除不保留的日志记录，并把剩余的日志发送给下游。
+func (lsp *logsSamplingProcessor) forward(ctx context.Context, ld plog.Logs) error {
+	ld.ResourceLogs().RemoveIf(func(rl plog.ResourceLogs) bool {
+		rl.ScopeLogs().RemoveIf(func(sl plog.ScopeLogs) bool {
+			sl.LogRecords().RemoveIf(func(lr plog.LogRecord) bool {
+				return !lsp.keep(lr.TraceID())
+			})
+			return sl.LogRecords().Len() == 0
+		})
+		return rl.ScopeLogs().Len() == 0
+	})
+	if ld.ResourceLogs().Len() == 0 {
+		return nil
+	}
+	return lsp.next.ConsumeLogs(ctx, ld)
+}
+
+// keep 判断一条日志记录是否保留。所属追踪被采样或不带追踪 ID 的日志总是保留；
+// 其余日志按追踪 ID 的哈希以 UnsampledRatio 保留，同一追踪的日志同进同退。
+func (lsp *logsSamplingProcessor) keep(traceID pcommon.TraceID) bool {
+	if traceID.IsEmpty() || lsp.store.Lookup(traceID) == decisions.Sampled {
+		return true
+	}
+	ratio := lsp.cfg.UnsampledRatio
+	if ratio <= 0 {
+		return false
+	}
+	if ratio >= 1 {
+		return true
+	}
+	// W3C 追踪 ID 的低 8 字节是随机的
+	return float64(binary.BigEndian.Uint64(traceID[8:])) < ratio*math.MaxUint64
+}
+
+// release 过滤并转发等待结束的日志。
+func (lsp *logsSamplingProcessor) release(ctx context.Context, batches []pendingLogs) {
+	for _, p := range batches {
+		if err := lsp.forward(ctx, p.logs); err != nil {
+			lsp.logger.Error("Failed to send logs to next consumer", zap.Error(err))
+		}
+	}
+}
+
+// takeReady 取出所有追踪都已有决策或等待时间已超过 Wait 的日志，其余日志保持到达顺序继续等待。
+// all 为 true 时取出全部日志。
+func (lsp *logsSamplingProcessor) takeReady(now time.Time, all bool) []pendingLogs {
+	lsp.mu.Lock()
+	defer lsp.mu.Unlock()
+
+	var ready []pendingLogs
+	remaining := lsp.pending[:0]
+	for _, p := range lsp.pending {
+		if all || now.Sub(p.arrival) >= lsp.cfg.Wait || lsp.decided(p.logs) {
+			lsp.pendingRecords -= p.logs.LogRecordCount()
+			ready = append(ready, p)
+			continue
+		}
+		remaining = append(remaining, p)
+	}
+	clear(lsp.pending[len(remaining):])
+	lsp.pending = remaining
+	return ready
+}
+
+func (lsp *logsSamplingProcessor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+func (lsp *logsSamplingProcessor) Start(_ context.Context, _ component.Host) error {
+	// 每个周期转发已有决策的日志，Wait 只是等待的上限
+	interval := lsp.cfg.Wait / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+
+	lsp.wg.Add(1)
+	go func() {
+		defer lsp.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lsp.done:
+				return
+			case now := <-ticker.C:
+				lsp.release(context.Background(), lsp.takeReady(now, false))
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown 按当前已知的决策处理所有仍在等待的日志。
+func (lsp *logsSamplingProcessor) Shutdown(ctx context.Context) error {
+	close(lsp.done)
+	lsp.wg.Wait()
+	lsp.release(ctx, lsp.takeReady(time.Now(), true))
+	lsp.store.Unsubscribe()
+	return nil
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/metadata"
+)
+
+// testLogs 为每个追踪 ID 生成一条日志记录，空 ID 表示不带追踪 ID 的日志。
+func testLogs(ids ...pcommon.TraceID) plog.Logs {
+	ld := plog.NewLogs()
+	lrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, id := range ids {
+		lrs.AppendEmpty().SetTraceID(id)
+	}
+	return ld
+}
+
+func newTestLogsProcessor(t *testing.T, cfg Config, sink *consumertest.LogsSink) *logsSamplingProcessor {
+	t.Helper()
+	p, err := newLogsProcessor(processortest.NewNopSettings(metadata.Type), sink, cfg)
+	require.NoError(t, err)
+	return p.(*logsSamplingProcessor)
+}
+
+func TestLogsProcessorForwardsDecided(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Logs.UnsampledRatio = 0
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+
+	lsp.store.Record([]pcommon.TraceID{{1}}, true)
+	lsp.store.Record([]pcommon.TraceID{{2}}, false)
+
+	require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{1}, pcommon.TraceID{2}, pcommon.TraceID{})))
+	assert.Empty(t, lsp.pending)
+	assert.Equal(t, 2, sink.LogRecordCount())
+
+	// 全部被丢弃的日志不发送给下游
+	require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{2})))
+	assert.Len(t, sink.AllLogs(), 1)
+}
+
+func TestLogsProcessorHoldsUntilDecision(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Logs.Wait = time.Minute
+	cfg.Logs.UnsampledRatio = 0
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+
+	require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{1}, pcommon.TraceID{2})))
+	assert.Zero(t, sink.LogRecordCount())
+	assert.Equal(t, 2, lsp.pendingRecords)
+
+	// 只有部分追踪有决策时日志继续缓存
+	now := time.Now()
+	assert.Empty(t, lsp.takeReady(now, false))
+	lsp.store.Record([]pcommon.TraceID{{1}}, true)
+	assert.Empty(t, lsp.takeReady(now, false))
+
+	// 所有追踪都有决策后不等 Wait 结束即可转发
+	lsp.store.Record([]pcommon.TraceID{{2}}, false)
+	ready := lsp.takeReady(now, false)
+	require.Len(t, ready, 1)
+	assert.Zero(t, lsp.pendingRecords)
+
+	lsp.release(context.Background(), ready)
+	assert.Equal(t, 1, sink.LogRecordCount())
+	assert.Equal(t, pcommon.TraceID{1}, sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).TraceID())
+}
+
+func TestLogsProcessorReleasesDecidedOutOfOrder(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Logs.Wait = time.Minute
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+
+	for i := byte(1); i <= 3; i++ {
+		require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{i})))
+	}
+	lsp.store.Record([]pcommon.TraceID{{2}}, true)
+
+	// 先到达的日志没有决策时不挡住后到达的已决策日志
+	ready := lsp.takeReady(time.Now(), false)
+	require.Len(t, ready, 1)
+	assert.Equal(t, pcommon.TraceID{2}, ready[0].logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).TraceID())
+	require.Len(t, lsp.pending, 2)
+	assert.Equal(t, 2, lsp.pendingRecords)
+	assert.Equal(t, pcommon.TraceID{1}, lsp.pending[0].logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).TraceID())
+	assert.Equal(t, pcommon.TraceID{3}, lsp.pending[1].logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).TraceID())
+}
+
+func TestLogsProcessorWaitTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio float64
+		want  int
+	}{
+		{name: "drop undecided", ratio: 0, want: 0},
+		{name: "keep undecided", ratio: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Logs.Wait = time.Minute
+			cfg.Logs.UnsampledRatio = tt.ratio
+			sink := new(consumertest.LogsSink)
+			lsp := newTestLogsProcessor(t, cfg, sink)
+
+			require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{1})))
+			lsp.release(context.Background(), lsp.takeReady(time.Now().Add(cfg.Logs.Wait), false))
+			assert.Equal(t, tt.want, sink.LogRecordCount())
+			assert.Empty(t, lsp.pending)
+		})
+	}
+}
+
+func TestLogsProcessorDecisionTTL(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Decisions.TTL = 10 * time.Millisecond
+	cfg.Logs.Wait = time.Minute
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+
+	lsp.store.Record([]pcommon.TraceID{{1}}, true)
+	time.Sleep(2 * cfg.Decisions.TTL)
+
+	// 决策过期后日志重新等待决策
+	require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{1})))
+	assert.Zero(t, sink.LogRecordCount())
+	assert.Equal(t, 1, lsp.pendingRecords)
+}
+
+func TestLogsProcessorMaxPendingRecords(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Logs.Wait = time.Minute
+	cfg.Logs.UnsampledRatio = 1
+	cfg.Logs.MaxPendingRecords = 2
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+
+	for i := byte(1); i <= 3; i++ {
+		require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{i})))
+	}
+	// 超过上限时最早的日志提前释放
+	assert.Equal(t, 1, sink.LogRecordCount())
+	assert.Equal(t, 2, lsp.pendingRecords)
+	assert.Equal(t, pcommon.TraceID{1}, sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).TraceID())
+}
+
+func TestLogsProcessorShutdownReleases(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Logs.Wait = time.Hour
+	cfg.Logs.UnsampledRatio = 0
+	sink := new(consumertest.LogsSink)
+	lsp := newTestLogsProcessor(t, cfg, sink)
+	require.NoError(t, lsp.Start(context.Background(), componenttest.NewNopHost()))
+
+	require.NoError(t, lsp.ConsumeLogs(context.Background(), testLogs(pcommon.TraceID{1}, pcommon.TraceID{2})))
+	lsp.store.Record([]pcommon.TraceID{{2}}, true)
+
+	require.NoError(t, lsp.Shutdown(context.Background()))
+	assert.Equal(t, 1, sink.LogRecordCount())
+	assert.Empty(t, lsp.pending)
+	// 关闭后不再订阅决策存储
+	assert.False(t, lsp.store.HasReaders())
+}
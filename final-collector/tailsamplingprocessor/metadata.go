@@ -9,4 +9,5 @@ import (
 var (
 	Type            = component.MustNewType("tail_sampling")
 	TracesStability = component.StabilityLevelBeta
+	LogsStability   = component.StabilityLevelDevelopment
 )
@@ -12,27 +12,55 @@ import (
 	"context"
 	"math"
 	"math/rand"
+	"net/http"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
 
+	"github.com/samplingCollector/tailsamplingprocessor/internal/decisions"
+	"github.com/samplingCollector/tailsamplingprocessor/internal/metadata"
+	"github.com/samplingCollector/tailsamplingprocessor/internal/statesync"
 	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
 )
 
 type tailSamplingSpanProcessor struct {
-	ctx          context.Context
-	set          processor.Settings
-	logger       *zap.Logger
-	nextConsumer consumer.Traces
-	config       Config
-	buffer       *tracepicker.SharedBuffer
-	encoder      *tracepicker.BFSEncoder
-	pathCounter  sync.Map
+	ctx             context.Context
+	set             processor.Settings
+	logger          *zap.Logger
+	nextConsumer    consumer.Traces
+	config          Config
+	errorClassifier *tracepicker.ErrorClassifier
+	labels          *tracepicker.LabelNormalizer
+	features        tracepicker.FeatureSpec
+	objective       tracepicker.Objective
+	telemetry       *metadata.TelemetryBuilder
+	syncer          *statesync.Syncer
+	decisionStore   *decisions.Store
+
+	// 每个租户独立的采样状态，见 tenant.go
+	tenantsMu sync.RWMutex
+	tenants   map[string]*tenant
+
+	// 流式采样模式，见 streaming.go
+	streamDone chan struct{}
+	streamWg   sync.WaitGroup
+
+	// 运行时可修改的参数，见 runtime_control.go
+	params        atomic.Pointer[runtimeParams]
+	paramsMu      sync.Mutex
+	controlServer *http.Server
+	controlDone   chan struct{}
+	controlWg     sync.WaitGroup
 }
 
 func newTracesProcessor(
@@ -42,19 +70,55 @@ func newTracesProcessor(
 	cfg Config,
 ) (processor.Traces, error) {
 	// ... 构造函数保持不变 ...
-	histPool := tracepicker.NewHistPool(cfg.PoolHeight)
-	encoder := tracepicker.NewBFSEncoder(histPool)
-	buffer := tracepicker.NewSharedBuffer(cfg.BufferSize)
+	normalizerCfg, err := cfg.LabelNormalization.normalizerConfig()
+	if err != nil {
+		return nil, err
+	}
+	labels := tracepicker.NewLabelNormalizer(normalizerCfg)
+	var errorClassifier *tracepicker.ErrorClassifier
+	if cfg.ErrorClasses.Enabled {
+		errorClassifier = tracepicker.NewErrorClassifier(labels, cfg.ErrorClasses.Attributes)
+	}
+	features, err := cfg.LatencyFeatures.featureSpec()
+	if err != nil {
+		return nil, err
+	}
+	objective, err := cfg.Objective.objective()
+	if err != nil {
+		return nil, err
+	}
+
+	telemetry, err := metadata.NewTelemetryBuilder(set.TelemetrySettings)
+	if err != nil {
+		return nil, err
+	}
 
 	tsp := &tailSamplingSpanProcessor{
-		ctx:          ctx,
-		set:          set,
-		logger:       set.Logger,
-		nextConsumer: nextConsumer,
-		config:       cfg,
-		buffer:       buffer,
-		encoder:      encoder,
+		ctx:             ctx,
+		set:             set,
+		logger:          set.Logger,
+		nextConsumer:    nextConsumer,
+		config:          cfg,
+		errorClassifier: errorClassifier,
+		labels:          labels,
+		features:        features,
+		objective:       objective,
+		telemetry:       telemetry,
+		decisionStore:   decisions.Shared(cfg.Decisions.Name, cfg.Decisions.TTL, cfg.Decisions.MaxEntries),
+		tenants:         make(map[string]*tenant),
 	}
+	params := cfg.runtimeParams()
+	if params.AbnormalSigma <= 0 {
+		params.AbnormalSigma = tracepicker.DefaultAbnormalSigma
+	}
+	tsp.params.Store(&params)
+
+	// 默认租户总是存在，不分租户时所有追踪都属于它
+	defaultTenant := ""
+	if cfg.Tenancy.Attribute != "" {
+		defaultTenant = cfg.Tenancy.DefaultTenant
+	}
+	tsp.tenants[defaultTenant] = tsp.newTenant(defaultTenant)
 
 	return tsp, nil
 }
@@ -71,52 +135,120 @@ func NewTracesProcessor(
 
 // 【核心变更】ConsumeTraces 现在是非阻塞的
 func (tsp *tailSamplingSpanProcessor) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
-	typeID, isAbnormal := tsp.encoder.Encode(td)
-	tsp.buffer.Add(typeID, td, isAbnormal)
+	t := tsp.tenantFor(td)
+	// 一次遍历完成编码和特征提取，之后各阶段只使用这条记录。
+	// 只有进入延迟矩阵的正常和异常追踪登记标签并提取特征，流式模式、新类型和单独处理的不完整追踪都不需要
+	var incomplete, isNovel bool
+	record := t.encoder.Ingest(td, tsp.features, func(enc tracepicker.Encoding) *tracepicker.LabelRegistry {
+		// 不完整追踪按 incomplete_traces.policy 处理，不计入新类型
+		if enc.Incomplete && tsp.config.IncompleteTraces.Policy != incompleteSample {
+			incomplete = true
+			return nil
+		}
+		isNovel = t.typeRegistry != nil && t.typeRegistry.Observe(enc.TypeID)
+		if isNovel || t.reservoir != nil {
+			return nil
+		}
+		return t.labels
+	})
+	enc := record.Encoding
+	isAbnormal := enc.IsAbnormal
+	if tsp.config.RedMetrics.Enabled {
+		tsp.recordRED(t, enc)
+	}
+	// budget 策略的不完整追踪在批次中单独采样
+	budgeted := false
+	if incomplete {
+		if tsp.handleIncomplete(t, td) {
+			return nil
+		}
+		budgeted = true
+	}
+	if t.reservoir != nil {
+		tsp.consumeStreaming(t, td, enc, isNovel)
+		return nil
+	}
+	var errorClass string
+	if isAbnormal && !budgeted && !isNovel && tsp.errorClassifier != nil {
+		errorClass = tsp.errorClassifier.Classify(td)
+	}
+	entry := tracepicker.Entry{
+		Trace:        td,
+		Record:       record,
+		IsNovel:      isNovel,
+		ErrorClass:   errorClass,
+		IsIncomplete: budgeted,
+	}
+	if err := t.buffer.Add(entry); err != nil {
+		tsp.logger.Warn("Failed to spill trace payload, keeping it in memory",
+			zap.String("tenant", t.name), zap.Error(err))
+	}
 
 	// 简化的日志，只在缓冲区状态变化时输出
-	bufferCount := tsp.buffer.Count()
-	if bufferCount%10 == 0 || tsp.buffer.IsFull() {
+	bufferCount := t.buffer.Count()
+	fullReason := t.buffer.FullReason()
+	if bufferCount%10 == 0 || fullReason != "" {
 		tsp.logger.Info("Buffer status",
+			zap.String("tenant", t.name),
 			zap.Uint64("count", bufferCount),
-			zap.Uint64("limit", tsp.config.BufferSize),
-			zap.Bool("full", tsp.buffer.IsFull()))
+			zap.Uint64("limit", t.buffer.Limits().Traces),
+			zap.Uint64("spans", t.buffer.SpanCount()),
+			zap.Uint64("bytes", t.buffer.ByteCount()),
+			zap.Bool("full", fullReason != ""))
 	}
 
-	if tsp.buffer.IsFull() {
+	if fullReason != "" {
 		tsp.logger.Info("🎯 Buffer full, triggering tail sampling",
-			zap.Uint64("traces", bufferCount))
+			zap.String("tenant", t.name),
+			zap.Uint64("traces", bufferCount),
+			zap.String("reason", fullReason))
 		// 1. 原子地换出数据副本并清空原缓冲区
-		normalTraces, abnormalTraces, count := tsp.buffer.SwapAndClear()
+		batch := t.buffer.SwapAndClear()
 
 		// 2. 将耗时的采样工作放到后台goroutine中执行，让ConsumeTraces立刻返回
-		go tsp.runBatchSampling(normalTraces, abnormalTraces, count)
+		go tsp.runBatchSampling(t, batch)
 	}
 	return nil
 }
 
-// 【核心变更】runBatchSampling 现在接收数据副本作为参数
-func (tsp *tailSamplingSpanProcessor) runBatchSampling(
-	normalTracesByType map[string][]ptrace.Traces,
-	abnormalTraces []ptrace.Traces,
-	bufferCount uint64,
-) {
+// 【核心变更】runBatchSampling 现在接收数据副本作为参数，批次只包含一个租户的追踪
+func (tsp *tailSamplingSpanProcessor) runBatchSampling(t *tenant, batch tracepicker.Batch) {
+	batchStart := time.Now()
+	defer tsp.releaseBatch(t, batch)
+	// 每个批次只读取一次运行时参数，批次内的运行时修改从下一批次开始生效
+	sampleRate := t.sampleRate(tsp.currentParams())
+	normalTracesByType := batch.Normal
+	abnormalRecords, abnormalTraces, abnormalProbs := tsp.sampleAbnormalByClass(batch, batch.Abnormal, batch.AbnormalClasses)
+	novelTraces := tsp.loadAll(batch, batch.Novel)
+	bufferCount := batch.Count
+
 	tsp.logger.Info("🔬 Starting tail sampling analysis...",
+		zap.String("tenant", t.name),
 		zap.Uint64("total_traces", bufferCount),
-		zap.Int("abnormal_traces", len(abnormalTraces)),
+		zap.Int("abnormal_traces", len(batch.Abnormal)),
+		zap.Int("novel_traces", len(novelTraces)),
 		zap.Int("normal_trace_types", len(normalTracesByType)))
 
-	// 1. 优先保留所有异常追踪
+	// 1. 优先保留所有异常追踪和新类型追踪。异常追踪在回退到随机采样时会被重新选择，
+	// 它们的 tracestate 在最终决策之后再写入
+	fallback := false
 	finalSampledTraces := make([]ptrace.Traces, 0, bufferCount)
 	finalSampledTraces = append(finalSampledTraces, abnormalTraces...)
+	finalSampledTraces = append(finalSampledTraces, novelTraces...)
+	for _, td := range novelTraces {
+		tsp.markSampled(td, 1)
+	}
+	tsp.recordKept(t, keepReasonAbnormal, len(abnormalTraces))
+	tsp.recordKept(t, keepReasonNovel, len(novelTraces))
 
-	// 2. 计算剩余采样配额
-	totalSampleCount := int(float64(bufferCount) * tsp.config.SampleRate)
+	// 2. 计算剩余采样配额，单独分配预算的不完整追踪不计入
+	totalSampleCount := int(float64(bufferCount-uint64(len(batch.Incomplete))) * sampleRate)
 	currentQuota := totalSampleCount - len(finalSampledTraces)
 
 	tsp.logger.Info("📊 Sampling calculation",
 		zap.Int("target_sample_count", totalSampleCount),
 		zap.Int("abnormal_kept", len(abnormalTraces)),
+		zap.Int("novel_kept", len(novelTraces)),
 		zap.Int("remaining_quota", currentQuota))
 
 	if currentQuota > 0 && len(normalTracesByType) > 0 {
@@ -126,17 +258,13 @@ func (tsp *tailSamplingSpanProcessor) runBatchSampling(
 			typeCounts[code] = len(traces)
 		}
 
-		historicalCounts := make(map[string]int)
-		tsp.pathCounter.Range(func(key, value interface{}) bool {
-			historicalCounts[key.(string)] = value.(int)
-			return true
-		})
+		historicalCounts := tsp.historicalCounts(t)
 
 		quotaMap := tracepicker.AllocateQuota(typeCounts, historicalCounts, currentQuota)
 
 		// 4. 调用演化算法进行分组采样
-		// (数据准备部分逻辑与之前版本相同)
-		allLabels, label2idx := getAllLabelsAndMap(normalTracesByType, abnormalTraces)
+		// 标签下标来自租户的 LabelRegistry，所有批次一致。追踪的标签在进入缓冲区时已登记
+		allLabels, _ := t.labels.Snapshot()
 		var sortedTypes []string
 		for typeID := range normalTracesByType {
 			sortedTypes = append(sortedTypes, typeID)
@@ -144,93 +272,433 @@ func (tsp *tailSamplingSpanProcessor) runBatchSampling(
 		sort.Strings(sortedTypes)
 
 		var quotas, bases []int
-		var allNormalTraces []ptrace.Traces
+		var allNormal []tracepicker.Record
+		var probabilities []float64 // 每条正常追踪所属类型的采样概率 配额/追踪数
 		for _, typeID := range sortedTypes {
-			traces := normalTracesByType[typeID]
-			bases = append(bases, len(traces))
-			allNormalTraces = append(allNormalTraces, traces...)
+			records := normalTracesByType[typeID]
+			bases = append(bases, len(records))
+			allNormal = append(allNormal, records...)
 			quotas = append(quotas, quotaMap[typeID])
+			p := math.Min(1, float64(quotaMap[typeID])/float64(len(records)))
+			for range records {
+				probabilities = append(probabilities, p)
+			}
 		}
 
-		rawDist := buildLatencyMatrix(allNormalTraces, label2idx, allLabels)
-		abDist := buildLatencyMatrix(abnormalTraces, label2idx, allLabels)
+		rawDist := denseLatencyMatrix(tsp.features, allNormal, len(allLabels))
+		abDist := denseLatencyMatrix(tsp.features, abnormalRecords, len(allLabels))
+		objective := tsp.batchObjective(t, allLabels)
 
-		problem, err := tracepicker.NewSampleProblem(rawDist, abDist, quotas, bases, tsp.config.CombinationCount, 1)
-		if err != nil {
-			tsp.logger.Error("Failed to create sample problem", zap.Error(err))
-			return
-		}
+		if tsp.config.Sampler == samplerCluster {
+			clustered, selected := tsp.clusterSampling(t, batch, rawDist, abDist, quotas, bases, objective, allNormal)
+			if len(selected) == 0 {
+				tsp.logger.Warn("Cluster sampling selected no traces, falling back to simple random sampling")
 
-		// 使用简化版本的优化器
-		optimizerSimple := tracepicker.NewSampleOptimizerSimple(problem)
-		bestSimple, err := optimizerSimple.OptimizeWithSimpleFallback()
-		if err != nil {
-			tsp.logger.Warn("Simple genetic algorithm optimization failed, trying advanced version",
-				zap.Error(err))
-
-			// 尝试高级版本
-			advancedProblem, err := tracepicker.ConvertToSampleProblemAdvanced(problem, tsp.config.CombinationCount)
+				// 回退到简单随机采样，新类型追踪仍然保留
+				fallback = true
+				finalSampledTraces = tsp.simpleRandomSampling(batch, allNormal, abnormalRecords, abnormalProbs, int(bufferCount), sampleRate)
+				tsp.recordKept(t, keepReasonRandomFallback, len(finalSampledTraces))
+				finalSampledTraces = append(finalSampledTraces, novelTraces...)
+			} else {
+				finalSampledTraces = append(finalSampledTraces, clustered...)
+				tsp.updateCumulative(t, allLabels, rawDist, abDist, selected)
+			}
+		} else {
+			problem, err := tracepicker.NewSampleProblem(rawDist, abDist, quotas, bases, tsp.config.CombinationCount, 1,
+				tracepicker.CandidateStrategy(tsp.config.CandidateStrategy), objective)
 			if err != nil {
-				tsp.logger.Warn("Failed to convert to advanced problem, using simple random sampling",
-					zap.Error(err))
+				tsp.logger.Warn("Failed to create sample problem, using simple random sampling", zap.Error(err))
 
-				// 回退到简单随机采样
-				finalSampledTraces = tsp.simpleRandomSampling(allNormalTraces, abnormalTraces, int(bufferCount))
+				// 回退到简单随机采样，新类型追踪仍然保留
+				fallback = true
+				finalSampledTraces = tsp.simpleRandomSampling(batch, allNormal, abnormalRecords, abnormalProbs, int(bufferCount), sampleRate)
+				tsp.recordKept(t, keepReasonRandomFallback, len(finalSampledTraces))
+				finalSampledTraces = append(finalSampledTraces, novelTraces...)
 			} else {
-				// 使用高级版本的优化器
-				optimizerAdvanced := tracepicker.NewSampleOptimizerAdvanced(advancedProblem)
-				bestAdvanced, err := optimizerAdvanced.OptimizeWithAdvancedFallback()
+				if t.elites != nil {
+					problem.Seed(t.elites.Seeds(sortedTypes))
+				}
+
+				// 使用简化版本的优化器
+				optimizerSimple := tracepicker.NewSampleOptimizerSimple(problem)
+				optimizerSimple.Budget = tsp.optimizeBudget(batchStart)
+				bestSimple, err := optimizerSimple.OptimizeWithSimpleFallback()
+				tsp.recordOptimizer(t, optimizerSimple.Stats)
 				if err != nil {
-					tsp.logger.Warn("Advanced genetic algorithm optimization failed, falling back to simple random sampling",
+					tsp.logger.Warn("Simple genetic algorithm optimization failed, trying advanced version",
 						zap.Error(err))
 
-					// 回退到简单随机采样
-					finalSampledTraces = tsp.simpleRandomSampling(allNormalTraces, abnormalTraces, int(bufferCount))
+					// 尝试高级版本
+					advancedProblem, err := tracepicker.ConvertToSampleProblemAdvanced(problem, tsp.config.CombinationCount)
+					if err != nil {
+						tsp.logger.Warn("Failed to convert to advanced problem, using simple random sampling",
+							zap.Error(err))
+
+						// 回退到简单随机采样，新类型追踪仍然保留
+						fallback = true
+						finalSampledTraces = tsp.simpleRandomSampling(batch, allNormal, abnormalRecords, abnormalProbs, int(bufferCount), sampleRate)
+						tsp.recordKept(t, keepReasonRandomFallback, len(finalSampledTraces))
+						finalSampledTraces = append(finalSampledTraces, novelTraces...)
+					} else {
+						// 使用高级版本的优化器
+						optimizerAdvanced := tracepicker.NewSampleOptimizerAdvanced(advancedProblem)
+						optimizerAdvanced.Budget = tsp.optimizeBudget(batchStart)
+						bestAdvanced, err := optimizerAdvanced.OptimizeWithAdvancedFallback()
+						tsp.recordOptimizer(t, optimizerAdvanced.Stats)
+						if err != nil {
+							tsp.logger.Warn("Advanced genetic algorithm optimization failed, falling back to simple random sampling",
+								zap.Error(err))
+
+							// 回退到简单随机采样，新类型追踪仍然保留
+							fallback = true
+							finalSampledTraces = tsp.simpleRandomSampling(batch, allNormal, abnormalRecords, abnormalProbs, int(bufferCount), sampleRate)
+							tsp.recordKept(t, keepReasonRandomFallback, len(finalSampledTraces))
+							finalSampledTraces = append(finalSampledTraces, novelTraces...)
+						} else {
+							// 5. 根据高级优化结果获取最终要采样的追踪
+							finalIndices := advancedProblem.GetIdxsByVar(bestAdvanced.Genes)
+							tsp.recordSamplingQuality(t, advancedProblem, finalIndices)
+							tsp.updateCumulative(t, allLabels, rawDist, abDist, finalIndices)
+							tsp.recordElites(t, sortedTypes, problem, finalIndices)
+							tsp.recordKept(t, keepReasonOptimizer, len(finalIndices))
+							for _, idx := range finalIndices {
+								if td, ok := tsp.loadPayload(batch, allNormal, idx); ok {
+									tsp.markSampled(td, probabilities[idx])
+									finalSampledTraces = append(finalSampledTraces, td)
+								}
+							}
+
+							// 6. 更新历史采样计数
+							tsp.addPathCounts(t, sampledCountByType(allNormal, finalIndices))
+						}
+					}
 				} else {
-					// 5. 根据高级优化结果获取最终要采样的追踪
-					finalIndices := advancedProblem.GetIdxsByVar(bestAdvanced.Genes)
+					// 5. 根据优化结果获取最终要采样的追踪
+					finalIndices := problem.GetIdxsByVar(bestSimple.Genes)
+					tsp.recordSamplingQuality(t, problem, finalIndices)
+					tsp.updateCumulative(t, allLabels, rawDist, abDist, finalIndices)
+					tsp.recordElites(t, sortedTypes, problem, finalIndices)
+					tsp.recordKept(t, keepReasonOptimizer, len(finalIndices))
 					for _, idx := range finalIndices {
-						if idx < len(allNormalTraces) {
-							finalSampledTraces = append(finalSampledTraces, allNormalTraces[idx])
+						if td, ok := tsp.loadPayload(batch, allNormal, idx); ok {
+							tsp.markSampled(td, probabilities[idx])
+							finalSampledTraces = append(finalSampledTraces, td)
 						}
 					}
-				}
-			}
-		} else {
-			// 5. 根据优化结果获取最终要采样的追踪
-			finalIndices := problem.GetIdxsByVar(bestSimple.Genes)
-			for _, idx := range finalIndices {
-				if idx < len(allNormalTraces) {
-					finalSampledTraces = append(finalSampledTraces, allNormalTraces[idx])
-				}
-			}
 
-			// 6. 更新历史采样计数
-			sampledCountByType := make(map[string]int)
-			for _, idx := range finalIndices {
-				if idx < len(allNormalTraces) {
-					typeID, _ := tsp.encoder.Encode(allNormalTraces[idx])
-					sampledCountByType[typeID]++
+					// 6. 更新历史采样计数
+					tsp.addPathCounts(t, sampledCountByType(allNormal, finalIndices))
 				}
 			}
-			for typeID, count := range sampledCountByType {
-				c, _ := tsp.pathCounter.LoadOrStore(typeID, 0)
-				tsp.pathCounter.Store(typeID, c.(int)+count)
-			}
 		}
 	}
 
-	// 7. 将最终采样的追踪数据发送给下游消费者
+	if !fallback {
+		for i, td := range abnormalTraces {
+			tsp.markSampled(td, abnormalProbs[i])
+		}
+	}
+	finalSampledTraces = append(finalSampledTraces, tsp.sampleIncomplete(t, tsp.loadAll(batch, batch.Incomplete))...)
+
+	// 7. 将最终采样的追踪数据发送给下游消费者，并发布决策供日志处理器使用
 	tsp.exportTraces(finalSampledTraces)
+	tsp.recordDecisions(batch, finalSampledTraces)
 
 	// 计算采样统计
 	samplingRate := float64(len(finalSampledTraces)) / float64(bufferCount) * 100
 	tsp.logger.Info("✅ Tail sampling completed",
+		zap.String("tenant", t.name),
 		zap.Int("input_traces", int(bufferCount)),
 		zap.Int("output_traces", len(finalSampledTraces)),
 		zap.Float64("actual_sampling_rate", samplingRate))
 }
 
+// 追踪被保留的原因，作为 count_traces_kept 指标的 reason 属性。
+const (
+	keepReasonAbnormal       = "abnormal"
+	keepReasonNovel          = "novel"
+	keepReasonOptimizer      = "optimizer"
+	keepReasonCluster        = "cluster"
+	keepReasonReservoir      = "reservoir"
+	keepReasonIncomplete     = "incomplete"
+	keepReasonRandomFallback = "random_fallback"
+)
+
+// 采样器模式，见 Config.Sampler。
+const (
+	samplerGA        = "ga"
+	samplerCluster   = "cluster"
+	samplerStreaming = "streaming"
+)
+
+// clusterSampling 用聚类代表代替遗传算法选择正常追踪：每个类型内按配额聚类，每个簇保留一个代表，
+// 代表的采样概率为 1/簇大小。一致性误差与遗传算法使用相同的评分。
+// 没有选出任何代表时调用方回退到简单随机采样。
+func (tsp *tailSamplingSpanProcessor) clusterSampling(t *tenant, batch tracepicker.Batch, rawDist, abDist [][]float64, quotas, bases []int,
+	objective tracepicker.Objective, records []tracepicker.Record) ([]ptrace.Traces, []int) {
+	selected, weights := tracepicker.ClusterSample(rawDist, quotas, bases, rand.New(rand.NewSource(rand.Int63())))
+
+	if tsp.config.QualityBaselineSamples > 0 && len(selected) > 0 {
+		if scorer, err := tracepicker.NewQualityScorer(rawDist, abDist, quotas, bases, objective); err == nil {
+			tsp.recordSamplingQuality(t, scorer, selected)
+		}
+	}
+	tsp.recordKept(t, keepReasonCluster, len(selected))
+
+	result := make([]ptrace.Traces, 0, len(selected))
+	for i, idx := range selected {
+		if td, ok := tsp.loadPayload(batch, records, idx); ok {
+			tsp.markSampled(td, 1/float64(weights[i]))
+			result = append(result, td)
+		}
+	}
+	tsp.addPathCounts(t, sampledCountByType(records, selected))
+
+	tsp.logger.Info("🧩 Cluster sampling completed",
+		zap.Int("normal_traces", len(records)),
+		zap.Int("representatives", len(result)))
+	return result, selected
+}
+
+// sampledCountByType 统计 selected 中每个类型被选中的正常追踪数。
+func sampledCountByType(records []tracepicker.Record, selected []int) map[string]int {
+	counts := make(map[string]int)
+	for _, idx := range selected {
+		if idx < len(records) {
+			counts[records[idx].TypeID]++
+		}
+	}
+	return counts
+}
+
+// addPathCounts 将每个类型的采样数加入租户的 PathCounter，作为配额分配的历史计数和发布给其他副本的摘要。
+// 所有成功选出正常追踪的路径 (遗传算法、聚类和流式释放) 都调用它，回退到随机采样的批次不计入。
+func (tsp *tailSamplingSpanProcessor) addPathCounts(t *tenant, counts map[string]int) {
+	now := time.Now()
+	for typeID, count := range counts {
+		t.pathCounter.Add(now, typeID, count)
+	}
+}
+
+// updateCumulative 将本批次的原始数据和采样结果 (选中的正常追踪与全部异常追踪) 加入租户的累积数据。
+// 回退到随机采样的批次不加入。
+func (tsp *tailSamplingSpanProcessor) updateCumulative(t *tenant, allLabels []string, rawDist, abDist [][]float64, selected []int) {
+	if t.cumulative == nil {
+		return
+	}
+	seen := make([][]float64, 0, len(rawDist)+len(abDist))
+	seen = append(seen, rawDist...)
+	seen = append(seen, abDist...)
+	sampled := make([][]float64, 0, len(selected)+len(abDist))
+	for _, idx := range selected {
+		if idx < len(rawDist) {
+			sampled = append(sampled, rawDist[idx])
+		}
+	}
+	sampled = append(sampled, abDist...)
+	t.cumulative.Update(time.Now(), tsp.features.ColumnNames(allLabels), seen, sampled)
+}
+
+// recordElites 将遗传算法的最优解记入租户的精英存档，供下一批次播种。
+func (tsp *tailSamplingSpanProcessor) recordElites(t *tenant, types []string, problem *tracepicker.SampleProblem, selected []int) {
+	if t.elites == nil {
+		return
+	}
+	t.elites.Record(types, problem.RawDist, problem.Splits, selected)
+}
+
+// optimizeBudget 返回本批次遗传算法的预算，时间预算扣除批次已经用去的时间。
+func (tsp *tailSamplingSpanProcessor) optimizeBudget(batchStart time.Time) tracepicker.OptimizeBudget {
+	cfg := tsp.config.Optimizer
+	timeBudget := cfg.TimeBudget
+	if timeBudget == 0 {
+		timeBudget = tsp.config.DecisionWait
+	}
+	if timeBudget > 0 {
+		// 预算已用完时只评估初始种群
+		timeBudget = max(timeBudget-time.Since(batchStart), time.Nanosecond)
+	}
+	return tracepicker.OptimizeBudget{
+		Generations: cfg.MaxGenerations,
+		TimeBudget:  timeBudget,
+		Patience:    cfg.Patience,
+	}
+}
+
+// recordOptimizer 记录一次遗传算法实际运行的代数、耗时和结束原因。
+func (tsp *tailSamplingSpanProcessor) recordOptimizer(t *tenant, stats tracepicker.OptimizeStats) {
+	tsp.logger.Info("🧬 Genetic algorithm finished",
+		zap.String("tenant", t.name),
+		zap.Uint("generations", stats.Generations),
+		zap.Duration("elapsed", stats.Elapsed),
+		zap.String("stop_reason", string(stats.StopReason)),
+		zap.Float64("best_fitness", stats.BestFitness))
+
+	attrs := append(t.metricAttributes(), attribute.String("stop_reason", string(stats.StopReason)))
+	opt := metric.WithAttributes(attrs...)
+	tsp.telemetry.ProcessorTailSamplingOptimizerGenerations.Record(tsp.ctx, int64(stats.Generations), opt)
+	tsp.telemetry.ProcessorTailSamplingOptimizerDuration.Record(tsp.ctx, stats.Elapsed.Milliseconds(), opt)
+}
+
+// recordKept 按保留原因记录租户保留的追踪数。
+func (tsp *tailSamplingSpanProcessor) recordKept(t *tenant, reason string, n int) {
+	if n <= 0 {
+		return
+	}
+	attrs := append(t.metricAttributes(), attribute.String("reason", reason))
+	tsp.telemetry.ProcessorTailSamplingCountTracesKept.Add(tsp.ctx, int64(n), metric.WithAttributes(attrs...))
+}
+
+// recordRED 记录一条追踪在采样决策之前的 RED 指标。
+func (tsp *tailSamplingSpanProcessor) recordRED(t *tenant, enc tracepicker.Encoding) {
+	rootEndpoint := enc.RootLabel
+	if rootEndpoint == "" {
+		rootEndpoint = "unknown"
+	}
+	attrs := append(t.metricAttributes(), attribute.String("root_endpoint", rootEndpoint))
+	if t.redTypes != nil {
+		attrs = append(attrs, attribute.String("trace_type", t.redTypes.label(enc.TypeID)))
+	}
+	opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+	tsp.telemetry.ProcessorTailSamplingRedRequests.Add(tsp.ctx, 1, opt)
+	if enc.HasError {
+		tsp.telemetry.ProcessorTailSamplingRedErrors.Add(tsp.ctx, 1, opt)
+	}
+	tsp.telemetry.ProcessorTailSamplingRedDuration.Record(tsp.ctx, float64(enc.Duration)/float64(time.Millisecond), opt)
+}
+
+// redTypeSet 记录租户 RED 指标中已使用的 trace_type 取值，超过上限的新类型记为 "other"。
+type redTypeSet struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newRedTypeSet(maxTypes int) *redTypeSet {
+	return &redTypeSet{max: maxTypes, seen: make(map[string]struct{})}
+}
+
+// label 返回类型在指标上的 trace_type 取值。
+func (s *redTypeSet) label(typeID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seen[typeID]; ok {
+		return typeID
+	}
+	if s.max > 0 && len(s.seen) >= s.max {
+		return "other"
+	}
+	s.seen[typeID] = struct{}{}
+	return typeID
+}
+
+// recordSamplingQuality 计算本批次采样结果与随机采样基线的一致性误差，并记录为指标。
+// selected 必须是 problem 中的采样索引。
+func (tsp *tailSamplingSpanProcessor) recordSamplingQuality(t *tenant, problem tracepicker.QualityEvaluator, selected []int) {
+	if tsp.config.QualityBaselineSamples <= 0 || len(selected) == 0 {
+		return
+	}
+
+	quality := problem.EvaluateQuality(selected, tsp.config.QualityBaselineSamples)
+	tsp.logger.Info("📈 Sampling quality",
+		zap.Float64("sampled_error", quality.SampledError),
+		zap.Float64("random_baseline_error", quality.BaselineError),
+		zap.Float64("ratio", quality.Ratio))
+
+	if !isFinite(quality.SampledError) || !isFinite(quality.BaselineError) {
+		return
+	}
+	opt := metric.WithAttributes(t.metricAttributes()...)
+	tsp.telemetry.ProcessorTailSamplingBatchSampledConsistencyError.Record(tsp.ctx, quality.SampledError, opt)
+	tsp.telemetry.ProcessorTailSamplingBatchRandomConsistencyError.Record(tsp.ctx, quality.BaselineError, opt)
+	if isFinite(quality.Ratio) {
+		tsp.telemetry.ProcessorTailSamplingBatchConsistencyErrorRatio.Record(tsp.ctx, quality.Ratio, opt)
+	}
+}
+
+// recordDecisions 将本批次每条追踪的采样决策写入共享决策存储。没有日志处理器订阅时不记录。
+func (tsp *tailSamplingSpanProcessor) recordDecisions(batch tracepicker.Batch, sampled []ptrace.Traces) {
+	if !tsp.decisionStore.HasReaders() {
+		return
+	}
+	sampledIDs := traceIDs(sampled)
+	kept := make(map[pcommon.TraceID]struct{}, len(sampledIDs))
+	for _, id := range sampledIDs {
+		kept[id] = struct{}{}
+	}
+
+	var droppedIDs []pcommon.TraceID
+	collect := func(records []tracepicker.Record) {
+		for _, record := range records {
+			for _, id := range record.TraceIDs {
+				if _, ok := kept[id]; !ok {
+					droppedIDs = append(droppedIDs, id)
+				}
+			}
+		}
+	}
+	for _, records := range batch.Normal {
+		collect(records)
+	}
+	collect(batch.Abnormal)
+	collect(batch.Novel)
+	collect(batch.Incomplete)
+
+	tsp.decisionStore.Record(sampledIDs, true)
+	tsp.decisionStore.Record(droppedIDs, false)
+}
+
+// recordTraceDecisions 将一组追踪的采样决策写入共享决策存储。没有日志处理器订阅时不记录。
+func (tsp *tailSamplingSpanProcessor) recordTraceDecisions(traces []ptrace.Traces, sampled bool) {
+	if tsp.decisionStore.HasReaders() {
+		tsp.decisionStore.Record(traceIDs(traces), sampled)
+	}
+}
+
+// traceIDs 返回追踪列表中出现的所有追踪 ID，不重复。
+func traceIDs(traces []ptrace.Traces) []pcommon.TraceID {
+	seen := make(map[pcommon.TraceID]struct{})
+	var ids []pcommon.TraceID
+	for _, td := range traces {
+		rs := td.ResourceSpans()
+		for i := 0; i < rs.Len(); i++ {
+			ils := rs.At(i).ScopeSpans()
+			for j := 0; j < ils.Len(); j++ {
+				spans := ils.At(j).Spans()
+				for k := 0; k < spans.Len(); k++ {
+					id := spans.At(k).TraceID()
+					if _, ok := seen[id]; !ok {
+						seen[id] = struct{}{}
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// markSampled 在追踪的每个 span 的 tracestate 中写入采样概率 p 对应的 ot=th 阈值。
+// 每条追踪只在最终决策时标记一次，p 是它的最终保留概率
+// (例如先按错误类别降采样、再随机回退的追踪，p 是两次概率的乘积)。
+func (tsp *tailSamplingSpanProcessor) markSampled(td ptrace.Traces, p float64) {
+	if !tsp.config.WriteTraceState {
+		return
+	}
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ils := rs.At(i).ScopeSpans()
+		for j := 0; j < ils.Len(); j++ {
+			spans := ils.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				ts := spans.At(k).TraceState()
+				ts.FromRaw(tracepicker.UpdateTraceState(ts.AsRaw(), p))
+			}
+		}
+	}
+}
+
 // exportTraces 辅助函数保持不变。
 func (tsp *tailSamplingSpanProcessor) exportTraces(traces []ptrace.Traces) {
 	for _, td := range traces {
@@ -243,21 +711,42 @@ func (tsp *tailSamplingSpanProcessor) exportTraces(traces []ptrace.Traces) {
 // --- 组件生命周期方法 ---
 
 func (tsp *tailSamplingSpanProcessor) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	// 编码时会写入 span 的 service.name 属性，开启 write_tracestate 时还会修改 tracestate
+	return consumer.Capabilities{MutatesData: true}
 }
 
 func (tsp *tailSamplingSpanProcessor) Start(_ context.Context, _ component.Host) error {
+	if tsp.config.RuntimeControl.Endpoint != "" || tsp.config.RuntimeControl.File != "" {
+		if err := tsp.startRuntimeControl(); err != nil {
+			return err
+		}
+	}
+	if tsp.config.Sampler == samplerStreaming {
+		tsp.startStreaming()
+	}
+	if tsp.config.StateSync.Enabled {
+		return tsp.startStateSync()
+	}
 	return nil
 }
 
-func (tsp *tailSamplingSpanProcessor) Shutdown(_ context.Context) error {
+func (tsp *tailSamplingSpanProcessor) Shutdown(ctx context.Context) error {
+	tsp.shutdownRuntimeControl(ctx)
+	tsp.shutdownStreaming()
 	tsp.logger.Info("Processor is shutting down, processing remaining traces in the buffer...")
-	// 在关闭时，同步处理最后一批数据
-	normalTraces, abnormalTraces, count := tsp.buffer.SwapAndClear()
-	if count > 0 {
-		tsp.logger.Info("Processing remaining traces during shutdown", zap.Uint64("count", count))
-		tsp.runBatchSampling(normalTraces, abnormalTraces, count)
+	// 在关闭时，同步处理每个租户的最后一批数据
+	for _, t := range tsp.allTenants() {
+		batch := t.buffer.SwapAndClear()
+		if batch.Count > 0 {
+			tsp.logger.Info("Processing remaining traces during shutdown",
+				zap.String("tenant", t.name), zap.Uint64("count", batch.Count))
+			tsp.runBatchSampling(t, batch)
+		}
 	}
+	if tsp.syncer != nil {
+		tsp.syncer.Shutdown()
+	}
+	tsp.telemetry.Shutdown()
 	return nil
 }
 
@@ -269,120 +758,194 @@ func min(a, b int) int {
 	return b
 }
 
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
 // --- 新增的辅助函数 ---
 
-// getSpanLabel 从 span 中提取 "service:operation" 标签。
-func getSpanLabel(span ptrace.Span) string {
-	serviceName := "unknown.service"
-	if val, ok := span.Attributes().Get("service.name"); ok {
-		serviceName = val.Str()
-	}
-	return serviceName + ":" + span.Name()
-}
-
-// getAllLabelsAndMap 遍历所有追踪，获取唯一的标签列表和标签到索引的映射。
-func getAllLabelsAndMap(normalTraces map[string][]ptrace.Traces, abnormalTraces []ptrace.Traces) ([]string, map[string]int) {
-	labelSet := make(map[string]struct{})
-	for _, traces := range normalTraces {
-		for _, trace := range traces {
-			rs := trace.ResourceSpans()
-			for i := 0; i < rs.Len(); i++ {
-				ils := rs.At(i).ScopeSpans()
-				for j := 0; j < ils.Len(); j++ {
-					spans := ils.At(j).Spans()
-					for k := 0; k < spans.Len(); k++ {
-						labelSet[getSpanLabel(spans.At(k))] = struct{}{}
-					}
-				}
+// batchObjective 返回本批次的一致性目标，按 objective.label_weights 设置每个特征列的权重。
+// 同一标签的所有特征列使用该标签的权重，追踪级特征列的权重为 1。
+// 耗时类特征列按租户 HistPool 的长期历史范围标准化，使不同批次的一致性误差可以比较。
+// 开启 objective.cumulative_weight 时附带租户至今的累积数据。
+func (tsp *tailSamplingSpanProcessor) batchObjective(t *tenant, allLabels []string) tracepicker.Objective {
+	objective := tsp.objective
+	objective.ColumnRanges = tsp.features.ColumnRanges(allLabels, t.histPool)
+	if t.cumulative != nil {
+		objective.Cumulative = t.cumulative.Snapshot(tsp.features.ColumnNames(allLabels))
+	}
+	labelWeights := tsp.config.Objective.LabelWeights
+	if len(labelWeights) == 0 {
+		return objective
+	}
+
+	width := len(tsp.features.Features)
+	weights := make([]float64, tsp.features.Width(len(allLabels)))
+	for i := range weights {
+		weights[i] = 1
+	}
+	for i, label := range allLabels {
+		if w, ok := labelWeights[label]; ok {
+			for j := 0; j < width; j++ {
+				weights[i*width+j] = w
 			}
 		}
 	}
-	// (同样逻辑遍历 abnormalTraces)
+	objective.ColumnWeights = weights
+	return objective
+}
 
-	labels := make([]string, 0, len(labelSet))
-	for label := range labelSet {
-		labels = append(labels, label)
+// denseLatencyMatrix 将追踪的紧凑特征展开为优化器所需的延迟矩阵。
+// 每个标签占 len(features.Features) 列，开启 trace_critical_path 时末尾多一列。
+// 追踪中不存在的标签取 NaN (缺失值)，一致性目标只在包含该标签的追踪上比较分布。
+func denseLatencyMatrix(features tracepicker.FeatureSpec, records []tracepicker.Record, numLabels int) [][]float64 {
+	matrix := make([][]float64, len(records))
+	for i, record := range records {
+		matrix[i] = features.Dense(record.Features, numLabels)
 	}
-	sort.Strings(labels)
+	return matrix
+}
 
-	label2idx := make(map[string]int, len(labels))
-	for i, label := range labels {
-		label2idx[label] = i
+// loadPayload 读回 records[idx] 的完整数据，下标越界或读取失败时返回 false。
+func (tsp *tailSamplingSpanProcessor) loadPayload(batch tracepicker.Batch, records []tracepicker.Record, idx int) (ptrace.Traces, bool) {
+	if idx < 0 || idx >= len(records) {
+		return ptrace.Traces{}, false
+	}
+	td, err := batch.Load(records[idx])
+	if err != nil {
+		tsp.logger.Error("Failed to load sampled trace payload", zap.Error(err))
+		return ptrace.Traces{}, false
 	}
-	return labels, label2idx
+	return td, true
 }
 
-// buildLatencyMatrix 将追踪列表转换为优化器所需的延迟矩阵。
-func buildLatencyMatrix(traces []ptrace.Traces, label2idx map[string]int, allLabels []string) [][]float64 {
-	matrix := make([][]float64, len(traces))
-	for i, trace := range traces {
-		latencies := make([]float64, len(allLabels))
-		for j := range latencies {
-			latencies[j] = math.NaN() // 默认值为 NaN
+// loadAll 读回 records 的完整数据，跳过读取失败的追踪。
+func (tsp *tailSamplingSpanProcessor) loadAll(batch tracepicker.Batch, records []tracepicker.Record) []ptrace.Traces {
+	traces := make([]ptrace.Traces, 0, len(records))
+	for idx := range records {
+		if td, ok := tsp.loadPayload(batch, records, idx); ok {
+			traces = append(traces, td)
 		}
+	}
+	return traces
+}
 
-		rs := trace.ResourceSpans()
-		for j := 0; j < rs.Len(); j++ {
-			ils := rs.At(j).ScopeSpans()
-			for k := 0; k < ils.Len(); k++ {
-				spans := ils.At(k).Spans()
-				for l := 0; l < spans.Len(); l++ {
-					span := spans.At(l)
-					label := getSpanLabel(span)
-					if idx, ok := label2idx[label]; ok {
-						duration := span.EndTimestamp().AsTime().Sub(span.StartTimestamp().AsTime())
-						latencies[idx] = float64(duration.Milliseconds())
-					}
-				}
-			}
+// releaseBatch 删除批次溢出到磁盘的段文件。
+func (tsp *tailSamplingSpanProcessor) releaseBatch(t *tenant, batch tracepicker.Batch) {
+	if spilled := batch.SpilledBytes(); spilled > 0 {
+		tsp.logger.Info("Releasing spilled trace payloads",
+			zap.String("tenant", t.name), zap.Int64("bytes", spilled))
+	}
+	if err := batch.Release(); err != nil {
+		tsp.logger.Warn("Failed to remove payload segment", zap.String("tenant", t.name), zap.Error(err))
+	}
+}
+
+// sampleAbnormalByClass 按错误类别对异常追踪分组，每个类别最多保留 MaxPerClass 条，
+// 避免一个高频故障挤占其他较少见故障的样本。未开启错误分类时保留全部异常追踪。
+// 返回保留的记录、完整数据及其保留概率，三者一一对应。这里不写入 tracestate，
+// 调用方在最终决策后标记。
+func (tsp *tailSamplingSpanProcessor) sampleAbnormalByClass(batch tracepicker.Batch, records []tracepicker.Record, classes []string) ([]tracepicker.Record, []ptrace.Traces, []float64) {
+	keptRecords := make([]tracepicker.Record, 0, len(records))
+	keptTraces := make([]ptrace.Traces, 0, len(records))
+	keptProbs := make([]float64, 0, len(records))
+	keep := func(idx int, p float64) {
+		if td, ok := tsp.loadPayload(batch, records, idx); ok {
+			keptRecords = append(keptRecords, records[idx])
+			keptTraces = append(keptTraces, td)
+			keptProbs = append(keptProbs, p)
 		}
-		matrix[i] = latencies
 	}
-	return matrix
+
+	maxPerClass := tsp.config.ErrorClasses.MaxPerClass
+	if tsp.errorClassifier == nil || maxPerClass <= 0 {
+		for idx := range records {
+			keep(idx, 1)
+		}
+		return keptRecords, keptTraces, keptProbs
+	}
+
+	byClass := make(map[string][]int)
+	for i := range records {
+		byClass[classes[i]] = append(byClass[classes[i]], i)
+	}
+
+	for class, group := range byClass {
+		p := 1.0
+		if len(group) > maxPerClass {
+			p = float64(maxPerClass) / float64(len(group))
+			rand.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+			tsp.logger.Debug("Downsampling abnormal traces of error class",
+				zap.String("error_class", class),
+				zap.Int("traces", len(group)),
+				zap.Int("kept", maxPerClass))
+			group = group[:maxPerClass]
+		}
+		for _, idx := range group {
+			keep(idx, p)
+		}
+	}
+
+	if len(keptRecords) < len(records) {
+		tsp.logger.Info("Sampled abnormal traces by error class",
+			zap.Int("error_classes", len(byClass)),
+			zap.Int("abnormal_traces", len(records)),
+			zap.Int("abnormal_kept", len(keptRecords)))
+	}
+	return keptRecords, keptTraces, keptProbs
 }
 
-// simpleRandomSampling 实现简单的随机采样作为回退方案
-func (tsp *tailSamplingSpanProcessor) simpleRandomSampling(normalTraces, abnormalTraces []ptrace.Traces, totalTraces int) []ptrace.Traces {
+// simpleRandomSampling 实现简单的随机采样作为回退方案，只读回被选中的追踪。
+// abnormalProbs 是异常追踪此前按错误类别保留的概率，与回退的概率相乘后写入 tracestate。
+func (tsp *tailSamplingSpanProcessor) simpleRandomSampling(batch tracepicker.Batch, normal, abnormal []tracepicker.Record, abnormalProbs []float64, totalTraces int, sampleRate float64) []ptrace.Traces {
 	// 计算采样数量
-	sampleCount := int(float64(totalTraces) * tsp.config.SampleRate)
+	sampleCount := int(float64(totalTraces) * sampleRate)
 	if sampleCount <= 0 {
 		return []ptrace.Traces{}
 	}
 
-	var allTraces []ptrace.Traces
-	allTraces = append(allTraces, normalTraces...)
-	allTraces = append(allTraces, abnormalTraces...)
-
-	// 如果总数不足采样数量，返回全部
-	if len(allTraces) <= sampleCount {
+	// 下标 [0, len(normal)) 是正常追踪，之后是异常追踪
+	records := make([]tracepicker.Record, 0, len(normal)+len(abnormal))
+	records = append(append(records, normal...), abnormal...)
+	total := len(records)
+	p := 1.0
+	if total > sampleCount {
+		p = float64(sampleCount) / float64(total)
+	} else {
+		sampleCount = total
 		tsp.logger.Info("Total traces less than sample count, returning all traces",
-			zap.Int("total_traces", len(allTraces)),
+			zap.Int("total_traces", total),
 			zap.Int("sample_count", sampleCount))
-		return allTraces
 	}
 
-	// 随机采样
-	indices := make([]int, len(allTraces))
+	// Fisher-Yates shuffle，取前 sampleCount 个
+	indices := make([]int, total)
 	for i := range indices {
 		indices[i] = i
 	}
-
-	// Fisher-Yates shuffle
 	for i := len(indices) - 1; i > 0; i-- {
 		j := rand.Intn(i + 1)
 		indices[i], indices[j] = indices[j], indices[i]
 	}
 
-	// 取前 sampleCount 个
-	result := make([]ptrace.Traces, sampleCount)
-	for i := 0; i < sampleCount; i++ {
-		result[i] = allTraces[indices[i]]
+	result := make([]ptrace.Traces, 0, sampleCount)
+	for _, idx := range indices[:sampleCount] {
+		td, ok := tsp.loadPayload(batch, records, idx)
+		if !ok {
+			continue
+		}
+		if i := idx - len(normal); i >= 0 && i < len(abnormalProbs) {
+			tsp.markSampled(td, p*abnormalProbs[i])
+		} else {
+			tsp.markSampled(td, p)
+		}
+		result = append(result, td)
 	}
 
 	tsp.logger.Info("✅ Simple random sampling completed",
-		zap.Int("input_traces", len(allTraces)),
+		zap.Int("input_traces", total),
 		zap.Int("output_traces", len(result)),
-		zap.Float64("sampling_rate", float64(len(result))/float64(len(allTraces))*100))
+		zap.Float64("sampling_rate", float64(len(result))/float64(total)*100))
 
 	return result
 }
@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/processor/processortest"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/decisions"
+	"github.com/samplingCollector/tailsamplingprocessor/internal/metadata"
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+// testConfig 返回默认配置，决策存储使用测试独有的名称，避免测试之间共享决策。
+func testConfig(t *testing.T) Config {
+	cfg := *createDefaultConfig().(*Config)
+	cfg.Decisions.Name = t.Name()
+	return cfg
+}
+
+// subscribeDecisions 像日志处理器一样订阅测试的决策存储，追踪处理器只在有订阅者时记录决策。
+func subscribeDecisions(t *testing.T, cfg Config) {
+	store := decisions.Subscribe(cfg.Decisions.Name, cfg.Decisions.TTL, cfg.Decisions.MaxEntries)
+	t.Cleanup(store.Unsubscribe)
+}
+
+func newTestProcessor(t *testing.T, cfg Config, next consumer.Traces) *tailSamplingSpanProcessor {
+	t.Helper()
+	if next == nil {
+		next = consumertest.NewNop()
+	}
+	p, err := newTracesProcessor(context.Background(), processortest.NewNopSettings(metadata.Type), next, cfg)
+	require.NoError(t, err)
+	return p.(*tailSamplingSpanProcessor)
+}
+
+func TestRedTypeSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxTypes int
+		types    []string
+		want     []string
+	}{
+		{name: "unlimited", maxTypes: 0, types: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}},
+		{name: "over limit", maxTypes: 2, types: []string{"a", "b", "c", "a"}, want: []string{"a", "b", "other", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRedTypeSet(tt.maxTypes)
+			var got []string
+			for _, typeID := range tt.types {
+				got = append(got, s.label(typeID))
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// testTrace 返回一条只有根 span 的追踪，追踪 ID 的第一个字节为 id。
+func testTrace(id byte, name string, duration time.Duration, traceState string) ptrace.Traces {
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID(pcommon.TraceID{id})
+	span.SetSpanID(pcommon.SpanID{id, 1})
+	span.SetName(name)
+	start := time.Unix(1700000000, 0)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	span.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(duration)))
+	span.TraceState().FromRaw(traceState)
+	return td
+}
+
+// bufferTestTrace 编码一条追踪并直接放入租户的缓冲区，abnormal 强制其为异常追踪。
+func bufferTestTrace(t *testing.T, tsp *tailSamplingSpanProcessor, tn *tenant, td ptrace.Traces, abnormal bool, errorClass string) {
+	record := tn.encoder.Ingest(td, tsp.features, func(tracepicker.Encoding) *tracepicker.LabelRegistry { return tn.labels })
+	record.IsAbnormal = abnormal
+	require.NoError(t, tn.buffer.Add(tracepicker.Entry{Trace: td, Record: record, ErrorClass: errorClass}))
+}
+
+// exportedThresholds 返回导出的每条追踪 (按追踪 ID 的第一个字节) 根 span 的 tracestate。
+func exportedThresholds(sink *consumertest.TracesSink) map[byte]string {
+	states := make(map[byte]string)
+	for _, td := range sink.AllTraces() {
+		span := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+		states[span.TraceID()[0]] = span.TraceState().AsRaw()
+	}
+	return states
+}
+
+func TestBatchSamplingTraceState(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxPerClass int
+		abnormal    int
+		normal      int
+		// wantAbnormal 是保留的异常追踪的 tracestate
+		wantAbnormal string
+		// wantNormal 是保留的正常追踪的 tracestate，20 条中配额 9 条
+		wantNormal   string
+		wantAbnKept  int
+		wantNormKept int
+	}{
+		{
+			name:         "optimizer",
+			abnormal:     2,
+			normal:       20,
+			wantAbnormal: "ot=th:0",
+			wantNormal:   "ot=th:" + tracepicker.ThresholdFromProbability(9.0/20),
+			wantAbnKept:  2,
+			wantNormKept: 9,
+		},
+		{
+			name:         "error class cap",
+			maxPerClass:  1,
+			abnormal:     4,
+			normal:       0,
+			wantAbnormal: "ot=th:c",
+			wantAbnKept:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.WriteTraceState = true
+			cfg.SampleRate = 0.5
+			if tt.maxPerClass > 0 {
+				cfg.ErrorClasses.Enabled = true
+				cfg.ErrorClasses.MaxPerClass = tt.maxPerClass
+			}
+			sink := new(consumertest.TracesSink)
+			tsp := newTestProcessor(t, cfg, sink)
+			tn := tsp.tenantFor(ptrace.NewTraces())
+
+			id := byte(1)
+			for i := 0; i < tt.abnormal; i++ {
+				bufferTestTrace(t, tsp, tn, testTrace(id, "GET /error", time.Second, ""), true, "500")
+				id++
+			}
+			for i := 0; i < tt.normal; i++ {
+				bufferTestTrace(t, tsp, tn, testTrace(id, "GET /a", time.Duration(10+i)*time.Millisecond, ""), false, "")
+				id++
+			}
+			tsp.runBatchSampling(tn, tn.buffer.SwapAndClear())
+
+			abnKept, normKept := 0, 0
+			for id, state := range exportedThresholds(sink) {
+				if int(id) <= tt.abnormal {
+					abnKept++
+					assert.Equal(t, tt.wantAbnormal, state)
+				} else {
+					normKept++
+					assert.Equal(t, tt.wantNormal, state)
+				}
+			}
+			assert.Equal(t, tt.wantAbnKept, abnKept)
+			assert.Equal(t, tt.wantNormKept, normKept)
+		})
+	}
+}
+
+func TestSimpleRandomSamplingTraceState(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.WriteTraceState = true
+	tsp := newTestProcessor(t, cfg, nil)
+	tn := tsp.tenantFor(ptrace.NewTraces())
+
+	// 一条正常追踪，一条此前按错误类别以 50% 保留的异常追踪，一条上游以 25% 采样的异常追踪
+	bufferTestTrace(t, tsp, tn, testTrace(1, "GET /a", time.Millisecond, ""), false, "")
+	bufferTestTrace(t, tsp, tn, testTrace(2, "GET /error", time.Second, ""), true, "500")
+	bufferTestTrace(t, tsp, tn, testTrace(3, "GET /error", time.Second, "ot=th:c"), true, "500")
+	batch := tn.buffer.SwapAndClear()
+	var normal []tracepicker.Record
+	for _, records := range batch.Normal {
+		normal = append(normal, records...)
+	}
+
+	sink := new(consumertest.TracesSink)
+	for _, td := range tsp.simpleRandomSampling(batch, normal, batch.Abnormal, []float64{0.5, 1}, 3, 1) {
+		require.NoError(t, sink.ConsumeTraces(context.Background(), td))
+	}
+	// 回退概率与类别概率相乘后只写入一次，再与上游阈值取较大者
+	assert.Equal(t, map[byte]string{1: "ot=th:0", 2: "ot=th:8", 3: "ot=th:c"}, exportedThresholds(sink))
+}
+
+func TestConsumeTracesRegistersLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *Config)
+		trace     ptrace.Traces
+		want      int
+	}{
+		{name: "normal", trace: testTrace(1, "GET /a", 10*time.Millisecond, ""), want: 1},
+		{
+			name:      "streaming",
+			configure: func(cfg *Config) { cfg.Sampler = samplerStreaming },
+			trace:     testTrace(1, "GET /a", 10*time.Millisecond, ""),
+		},
+		{
+			name: "novel",
+			configure: func(cfg *Config) {
+				cfg.NovelTypes.Enabled = true
+				cfg.NovelTypes.KeepFirst = 1
+			},
+			trace: testTrace(1, "GET /a", 10*time.Millisecond, ""),
+		},
+		{
+			name:      "incomplete budget",
+			configure: func(cfg *Config) { cfg.IncompleteTraces.Policy = incompleteBudget },
+			trace:     incompleteTrace(1, false),
+		},
+		// sample 策略的不完整追踪与正常追踪一起进入延迟矩阵
+		{name: "incomplete sample", trace: incompleteTrace(1, false), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			if tt.configure != nil {
+				tt.configure(&cfg)
+			}
+			tsp := newTestProcessor(t, cfg, new(consumertest.TracesSink))
+
+			require.NoError(t, tsp.ConsumeTraces(context.Background(), tt.trace))
+			assert.Equal(t, tt.want, tsp.tenantFor(ptrace.NewTraces()).labels.Len())
+		})
+	}
+}
+
+func TestAddPathCounts(t *testing.T) {
+	tsp := newTestProcessor(t, testConfig(t), nil)
+	tn := tsp.tenantFor(ptrace.NewTraces())
+	records := []tracepicker.Record{
+		{TraceRecord: tracepicker.TraceRecord{Encoding: tracepicker.Encoding{TypeID: "a"}}},
+		{TraceRecord: tracepicker.TraceRecord{Encoding: tracepicker.Encoding{TypeID: "a"}}},
+		{TraceRecord: tracepicker.TraceRecord{Encoding: tracepicker.Encoding{TypeID: "b"}}},
+	}
+
+	// 越界的下标被忽略
+	counts := sampledCountByType(records, []int{0, 1, 2, 5})
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, counts)
+	tsp.addPathCounts(tn, counts)
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1}, tn.pathCounter.Snapshot(time.Now()))
+}
+
+func TestBatchSamplingProblemErrorFallsBack(t *testing.T) {
+	cfg := testConfig(t)
+	// 组合数小于 2 时无法创建采样问题
+	cfg.CombinationCount = 1
+	cfg.SampleRate = 0.5
+	subscribeDecisions(t, cfg)
+	sink := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t, cfg, sink)
+	tn := tsp.tenantFor(ptrace.NewTraces())
+
+	bufferTestTrace(t, tsp, tn, testTrace(1, "GET /error", time.Second, ""), true, "")
+	novel := testTrace(2, "GET /new", 10*time.Millisecond, "")
+	record := tn.encoder.Ingest(novel, tsp.features, func(tracepicker.Encoding) *tracepicker.LabelRegistry { return nil })
+	require.NoError(t, tn.buffer.Add(tracepicker.Entry{Trace: novel, Record: record, IsNovel: true}))
+	for id := byte(3); id <= 12; id++ {
+		bufferTestTrace(t, tsp, tn, testTrace(id, "GET /a", time.Duration(id)*time.Millisecond, ""), false, "")
+	}
+	tsp.runBatchSampling(tn, tn.buffer.SwapAndClear())
+
+	// 回退到简单随机采样：新类型追踪仍然导出，所有追踪都有决策
+	states := exportedThresholds(sink)
+	assert.Contains(t, states, byte(2))
+	assert.Len(t, states, 7)
+	for id := byte(1); id <= 12; id++ {
+		assert.NotEqual(t, decisions.Pending, tsp.decisionStore.Lookup(pcommon.TraceID{id}), "trace %d", id)
+	}
+}
+
+func TestRecordDecisionsRequiresReader(t *testing.T) {
+	cfg := testConfig(t)
+	tsp := newTestProcessor(t, cfg, new(consumertest.TracesSink))
+	tn := tsp.tenantFor(ptrace.NewTraces())
+
+	// 没有日志处理器订阅时不记录决策
+	bufferTestTrace(t, tsp, tn, testTrace(1, "GET /a", 10*time.Millisecond, ""), false, "")
+	tsp.runBatchSampling(tn, tn.buffer.SwapAndClear())
+	assert.Zero(t, tsp.decisionStore.Len())
+
+	subscribeDecisions(t, cfg)
+	bufferTestTrace(t, tsp, tn, testTrace(2, "GET /a", 10*time.Millisecond, ""), false, "")
+	tsp.runBatchSampling(tn, tn.buffer.SwapAndClear())
+	assert.Equal(t, 1, tsp.decisionStore.Len())
+}
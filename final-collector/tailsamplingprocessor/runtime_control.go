@@ -0,0 +1,235 @@
+// file: processor/tailsamplingprocessor/runtime_control.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// runtimeParams 是可以在运行时修改的采样参数。
+// 处理器持有一个不可变的快照，每个批次开始时读取一次，修改在批次之间生效。
+type runtimeParams struct {
+	SampleRate    float64 `json:"sample_rate"`
+	BufferSize    uint64  `json:"buffer_size"`
+	AbnormalSigma float64 `json:"abnormal_sigma"`
+}
+
+// runtimeUpdate 是一次参数修改，未设置的字段保持不变。
+type runtimeUpdate struct {
+	SampleRate    *float64 `json:"sample_rate,omitempty"`
+	BufferSize    *uint64  `json:"buffer_size,omitempty"`
+	AbnormalSigma *float64 `json:"abnormal_sigma,omitempty"`
+}
+
+// runtimeParams 返回配置中可在运行时修改的参数的初始值。
+func (cfg *Config) runtimeParams() runtimeParams {
+	return runtimeParams{
+		SampleRate:    cfg.SampleRate,
+		BufferSize:    cfg.BufferSize,
+		AbnormalSigma: cfg.AbnormalSigma,
+	}
+}
+
+// validateRuntimeParams 检查运行时参数，cfg 提供不可在运行时修改的其他缓冲区上限。
+func validateRuntimeParams(p runtimeParams, cfg Config) error {
+	if p.SampleRate <= 0 || p.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be in (0, 1], got %v", p.SampleRate)
+	}
+	if p.BufferSize == 0 && cfg.MaxBufferedSpans == 0 && cfg.MaxBufferedBytes == 0 {
+		return errors.New("at least one of buffer_size, max_buffered_spans or max_buffered_bytes must be set")
+	}
+	if p.AbnormalSigma <= 0 {
+		return fmt.Errorf("abnormal_sigma must be positive, got %v", p.AbnormalSigma)
+	}
+	return nil
+}
+
+// currentParams 返回当前生效的运行时参数快照。
+func (tsp *tailSamplingSpanProcessor) currentParams() runtimeParams {
+	return *tsp.params.Load()
+}
+
+// applyRuntimeUpdate 校验并应用一次参数修改，source 标明修改来源并写入日志。
+// 校验失败时不做任何修改。
+func (tsp *tailSamplingSpanProcessor) applyRuntimeUpdate(update runtimeUpdate, source string) (runtimeParams, error) {
+	tsp.paramsMu.Lock()
+	defer tsp.paramsMu.Unlock()
+
+	old := tsp.currentParams()
+	next := old
+	if update.SampleRate != nil {
+		next.SampleRate = *update.SampleRate
+	}
+	if update.BufferSize != nil {
+		next.BufferSize = *update.BufferSize
+	}
+	if update.AbnormalSigma != nil {
+		next.AbnormalSigma = *update.AbnormalSigma
+	}
+	if err := validateRuntimeParams(next, tsp.config); err != nil {
+		tsp.logger.Warn("Rejected runtime parameter update", zap.String("source", source), zap.Error(err))
+		return old, err
+	}
+	if next == old {
+		return old, nil
+	}
+
+	// 先发布新参数再更新已有租户，之后创建的租户直接读取新参数。
+	// 配置了覆盖的租户保留自己的采样率和缓冲区大小
+	tsp.params.Store(&next)
+	for _, t := range tsp.allTenants() {
+		t.buffer.SetLimits(t.bufferLimits(next, tsp.config))
+		t.encoder.SetAbnormalSigma(next.AbnormalSigma)
+	}
+
+	if next.SampleRate != old.SampleRate {
+		tsp.logger.Info("⚙️ Runtime parameter changed", zap.String("source", source),
+			zap.String("parameter", "sample_rate"), zap.Float64("old", old.SampleRate), zap.Float64("new", next.SampleRate))
+	}
+	if next.BufferSize != old.BufferSize {
+		tsp.logger.Info("⚙️ Runtime parameter changed", zap.String("source", source),
+			zap.String("parameter", "buffer_size"), zap.Uint64("old", old.BufferSize), zap.Uint64("new", next.BufferSize))
+	}
+	if next.AbnormalSigma != old.AbnormalSigma {
+		tsp.logger.Info("⚙️ Runtime parameter changed", zap.String("source", source),
+			zap.String("parameter", "abnormal_sigma"), zap.Float64("old", old.AbnormalSigma), zap.Float64("new", next.AbnormalSigma))
+	}
+	return next, nil
+}
+
+// startRuntimeControl 启动 HTTP 控制接口和参数文件监视。
+func (tsp *tailSamplingSpanProcessor) startRuntimeControl() error {
+	cfg := tsp.config.RuntimeControl
+	tsp.controlDone = make(chan struct{})
+
+	if cfg.Endpoint != "" {
+		listener, err := net.Listen("tcp", cfg.Endpoint)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/config", tsp.handleRuntimeConfig)
+		tsp.controlServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		tsp.controlWg.Add(1)
+		go func() {
+			defer tsp.controlWg.Done()
+			if err := tsp.controlServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				tsp.logger.Error("Runtime control server stopped", zap.Error(err))
+			}
+		}()
+		tsp.logger.Info("Runtime control endpoint started", zap.String("endpoint", listener.Addr().String()))
+	}
+
+	if cfg.File != "" {
+		tsp.controlWg.Add(1)
+		go tsp.watchRuntimeFile(cfg.File, cfg.PollInterval)
+	}
+	return nil
+}
+
+// shutdownRuntimeControl 停止 HTTP 控制接口和文件监视。
+func (tsp *tailSamplingSpanProcessor) shutdownRuntimeControl(ctx context.Context) {
+	if tsp.controlDone == nil {
+		return
+	}
+	close(tsp.controlDone)
+	if tsp.controlServer != nil {
+		if err := tsp.controlServer.Shutdown(ctx); err != nil {
+			tsp.logger.Warn("Failed to shut down runtime control server", zap.Error(err))
+		}
+	}
+	tsp.controlWg.Wait()
+}
+
+// handleRuntimeConfig 处理 /config 请求：GET 返回当前参数，PUT/POST 修改参数。
+func (tsp *tailSamplingSpanProcessor) handleRuntimeConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeRuntimeParams(w, tsp.currentParams())
+	case http.MethodPut, http.MethodPost:
+		update, err := decodeRuntimeUpdate(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		params, err := tsp.applyRuntimeUpdate(update, "http:"+r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeRuntimeParams(w, params)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeRuntimeParams(w http.ResponseWriter, params runtimeParams) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(params)
+}
+
+// decodeRuntimeUpdate 解析 JSON 格式的参数修改，拒绝未知字段。
+func decodeRuntimeUpdate(r io.Reader) (runtimeUpdate, error) {
+	var update runtimeUpdate
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&update); err != nil {
+		return update, fmt.Errorf("invalid runtime parameters: %w", err)
+	}
+	return update, nil
+}
+
+// watchRuntimeFile 周期性地检查参数文件，内容变化时应用其中的参数。
+// 文件不存在时不做修改，等待文件出现。
+func (tsp *tailSamplingSpanProcessor) watchRuntimeFile(path string, interval time.Duration) {
+	defer tsp.controlWg.Done()
+
+	var last []byte
+	check := func() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				tsp.logger.Warn("Failed to read runtime parameter file", zap.String("file", path), zap.Error(err))
+			}
+			return
+		}
+		if bytes.Equal(content, last) {
+			return
+		}
+		last = content
+
+		update, err := decodeRuntimeUpdate(bytes.NewReader(content))
+		if err != nil {
+			tsp.logger.Warn("Ignoring runtime parameter file", zap.String("file", path), zap.Error(err))
+			return
+		}
+		_, _ = tsp.applyRuntimeUpdate(update, "file:"+path)
+	}
+
+	check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tsp.controlDone:
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestRuntimeConfigEndpoint(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Tenancy.Attribute = "tenant"
+	cfg.Tenancy.Overrides = map[string]TenantOverrideCfg{"big": {BufferSize: 9000}}
+	tsp := newTestProcessor(t, cfg, nil)
+	tsp.tenants["big"] = tsp.newTenant("big")
+
+	get := func() runtimeParams {
+		rec := httptest.NewRecorder()
+		tsp.handleRuntimeConfig(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var params runtimeParams
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &params))
+		return params
+	}
+	initial := get()
+	assert.Equal(t, cfg.runtimeParams(), initial)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		code   int
+		want   runtimeParams
+	}{
+		{name: "partial update", method: http.MethodPut, body: `{"sample_rate": 0.5}`, code: http.StatusOK,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: initial.BufferSize, AbnormalSigma: initial.AbnormalSigma}},
+		{name: "post", method: http.MethodPost, body: `{"buffer_size": 100, "abnormal_sigma": 3}`, code: http.StatusOK,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: 100, AbnormalSigma: 3}},
+		{name: "malformed json", method: http.MethodPut, body: `{"sample_rate":`, code: http.StatusBadRequest,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: 100, AbnormalSigma: 3}},
+		{name: "unknown field", method: http.MethodPut, body: `{"sample_rate": 0.2, "pool_height": 1}`, code: http.StatusBadRequest,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: 100, AbnormalSigma: 3}},
+		{name: "out of range", method: http.MethodPut, body: `{"sample_rate": 0.2, "abnormal_sigma": -1}`, code: http.StatusBadRequest,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: 100, AbnormalSigma: 3}},
+		{name: "method not allowed", method: http.MethodDelete, code: http.StatusMethodNotAllowed,
+			want: runtimeParams{SampleRate: 0.5, BufferSize: 100, AbnormalSigma: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tsp.handleRuntimeConfig(rec, httptest.NewRequest(tt.method, "/config", strings.NewReader(tt.body)))
+			assert.Equal(t, tt.code, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.want, get())
+		})
+	}
+
+	// 新参数应用到已有租户，配置了覆盖的租户保留自己的缓冲区大小
+	assert.Equal(t, uint64(100), tsp.tenants[cfg.Tenancy.DefaultTenant].buffer.Limits().Traces)
+	assert.Equal(t, uint64(9000), tsp.tenants["big"].buffer.Limits().Traces)
+	assert.Equal(t, 3.0, tsp.tenants["big"].encoder.AbnormalSigma())
+}
+
+func TestRuntimeConfigFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.json")
+	cfg := testConfig(t)
+	cfg.RuntimeControl.File = path
+	cfg.RuntimeControl.PollInterval = 10 * time.Millisecond
+	tsp := newTestProcessor(t, cfg, nil)
+	require.NoError(t, tsp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, tsp.Shutdown(context.Background())) }()
+
+	// 文件不存在时保持原参数
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, cfg.SampleRate, tsp.currentParams().SampleRate)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"sample_rate": 0.25}`), 0o600))
+	require.Eventually(t, func() bool { return tsp.currentParams().SampleRate == 0.25 }, time.Second, 5*time.Millisecond)
+
+	// 无效内容被忽略，之后的有效内容继续生效
+	require.NoError(t, os.WriteFile(path, []byte(`{"sample_rate": 2}`), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0.25, tsp.currentParams().SampleRate)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"buffer_size": 50}`), 0o600))
+	require.Eventually(t, func() bool { return tsp.currentParams().BufferSize == 50 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 0.25, tsp.currentParams().SampleRate)
+}
@@ -0,0 +1,81 @@
+// file: processor/tailsamplingprocessor/state_sync.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"math"
+	"os"
+	"time"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/statesync"
+)
+
+// localState 将处理器的本地采样状态暴露给 statesync。
+// 每个租户的统计单独发布，对端只把它合并进同名的租户，不同租户的标签和类型不会混在一起。
+type localState struct {
+	tsp *tailSamplingSpanProcessor
+}
+
+func (s localState) TenantStates() map[string]statesync.TenantState {
+	now := time.Now()
+	tenants := s.tsp.allTenants()
+	states := make(map[string]statesync.TenantState, len(tenants))
+	for _, t := range tenants {
+		states[t.name] = statesync.TenantState{
+			Labels: t.histPool.Moments(),
+			Paths:  t.pathCounter.Snapshot(now),
+		}
+	}
+	return states
+}
+
+// startStateSync 启动副本间的状态共享。
+func (tsp *tailSamplingSpanProcessor) startStateSync() error {
+	cfg := tsp.config.StateSync
+	replicaID := cfg.ReplicaID
+	if replicaID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		replicaID = hostname
+	}
+
+	tsp.syncer = statesync.NewSyncer(statesync.Settings{
+		ReplicaID:     replicaID,
+		Endpoint:      cfg.Endpoint,
+		Peers:         cfg.Peers,
+		Interval:      cfg.Interval,
+		DecayHalfLife: cfg.DecayHalfLife,
+	}, localState{tsp: tsp}, tsp.applyRemoteState, tsp.logger)
+
+	return tsp.syncer.Start()
+}
+
+// applyRemoteState 将其他副本中同名租户的延迟统计合并进每个租户的 HistPool。
+func (tsp *tailSamplingSpanProcessor) applyRemoteState() {
+	for _, t := range tsp.allTenants() {
+		labels, _ := tsp.syncer.Remote(t.name)
+		t.histPool.SetRemoteMoments(labels)
+	}
+}
+
+// historicalCounts 返回租户每个类型的历史采样计数。
+// 开启状态共享时，本地计数按 decay_half_life 衰减，并包含其他副本中同名租户衰减后的计数。
+func (tsp *tailSamplingSpanProcessor) historicalCounts(t *tenant) map[string]int {
+	counts := t.pathCounter.Snapshot(time.Now())
+	if tsp.syncer != nil {
+		_, paths := tsp.syncer.Remote(t.name)
+		for typeID, count := range paths {
+			counts[typeID] += count
+		}
+	}
+
+	historicalCounts := make(map[string]int, len(counts))
+	for typeID, count := range counts {
+		historicalCounts[typeID] = int(math.Round(count))
+	}
+	return historicalCounts
+}
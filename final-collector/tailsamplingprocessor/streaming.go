@@ -0,0 +1,139 @@
+// file: processor/tailsamplingprocessor/streaming.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"math"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+// 流式采样模式 (sampler: streaming)：追踪不进入 SharedBuffer，
+// 异常和新类型追踪直接导出，其余追踪进入所属类型的加权蓄水池，
+// 每个 ReleaseInterval 释放一次，从进入到导出的延迟不超过 ReleaseInterval。
+// 每个类型的蓄水池容量每个 BudgetInterval 用 AllocateQuota 重新计算。
+// 租户还没有预算时，第一次释放前先按已到达的追踪和 sample_rate 计算一次，
+// 不必等待第一个 BudgetInterval。
+// 异常追踪在流式模式下直接导出，不经过 error_classes.max_per_class 的类别上限。
+
+// consumeStreaming 处理流式模式下到达租户 t 的一条追踪。
+func (tsp *tailSamplingSpanProcessor) consumeStreaming(t *tenant, td ptrace.Traces, enc tracepicker.Encoding, isNovel bool) {
+	if enc.IsAbnormal || isNovel {
+		reason := keepReasonAbnormal
+		if !enc.IsAbnormal {
+			reason = keepReasonNovel
+		}
+		tsp.keepNow(t, td, 1, reason)
+		return
+	}
+
+	durationMs := float64(enc.Duration) / float64(time.Millisecond)
+	if evicted, ok := t.reservoir.Offer(enc.TypeID, td, durationMs); ok {
+		tsp.recordTraceDecisions([]ptrace.Traces{evicted}, false)
+	}
+}
+
+// startStreaming 启动蓄水池的释放和预算计算。
+func (tsp *tailSamplingSpanProcessor) startStreaming() {
+	cfg := tsp.config.Streaming
+	tsp.streamDone = make(chan struct{})
+
+	tsp.streamWg.Add(1)
+	go func() {
+		defer tsp.streamWg.Done()
+		release := time.NewTicker(cfg.ReleaseInterval)
+		defer release.Stop()
+		budget := time.NewTicker(cfg.BudgetInterval)
+		defer budget.Stop()
+		for {
+			select {
+			case <-tsp.streamDone:
+				return
+			case <-release.C:
+				for _, t := range tsp.allTenants() {
+					if !t.reservoir.Budgeted() {
+						tsp.recomputeBudgets(t)
+					}
+					tsp.releaseReservoirs(t)
+				}
+			case <-budget.C:
+				for _, t := range tsp.allTenants() {
+					tsp.recomputeBudgets(t)
+				}
+			}
+		}
+	}()
+}
+
+// shutdownStreaming 停止后台任务并释放蓄水池中剩余的追踪。
+func (tsp *tailSamplingSpanProcessor) shutdownStreaming() {
+	if tsp.streamDone == nil {
+		return
+	}
+	close(tsp.streamDone)
+	tsp.streamWg.Wait()
+	for _, t := range tsp.allTenants() {
+		tsp.releaseReservoirs(t)
+	}
+}
+
+// releaseReservoirs 导出租户所有蓄水池中的追踪。
+func (tsp *tailSamplingSpanProcessor) releaseReservoirs(t *tenant) {
+	released := t.reservoir.Release()
+	if len(released) == 0 {
+		return
+	}
+
+	traces := make([]ptrace.Traces, 0, len(released))
+	sampledCountByType := make(map[string]int)
+	for _, r := range released {
+		tsp.markSampled(r.Trace, r.Probability)
+		traces = append(traces, r.Trace)
+		sampledCountByType[r.TypeID]++
+	}
+	tsp.addPathCounts(t, sampledCountByType)
+
+	tsp.recordKept(t, keepReasonReservoir, len(traces))
+	tsp.exportTraces(traces)
+	tsp.recordTraceDecisions(traces, true)
+	tsp.logger.Debug("Released traces from reservoirs",
+		zap.String("tenant", t.name),
+		zap.Int("traces", len(traces)),
+		zap.Int("types", len(sampledCountByType)))
+}
+
+// recomputeBudgets 按上次计算以来租户每个类型的到达数和租户的采样率重新分配配额，
+// 并换算为每个释放周期的蓄水池容量，从下一个释放周期开始生效。
+func (tsp *tailSamplingSpanProcessor) recomputeBudgets(t *tenant) {
+	arrivals, elapsed := t.reservoir.Arrivals()
+	total := 0
+	for _, n := range arrivals {
+		total += n
+	}
+	if total == 0 || elapsed <= 0 {
+		return
+	}
+
+	cfg := tsp.config.Streaming
+	totalQuota := int(math.Round(float64(total) * t.sampleRate(tsp.currentParams())))
+	quotaMap := tracepicker.AllocateQuota(arrivals, tsp.historicalCounts(t), totalQuota)
+
+	scale := float64(cfg.ReleaseInterval) / float64(elapsed)
+	budgets := make(map[string]int, len(arrivals))
+	for typeID := range arrivals {
+		budgets[typeID] = int(math.Ceil(float64(quotaMap[typeID]) * scale))
+	}
+	t.reservoir.SetBudgets(budgets)
+
+	tsp.logger.Info("📊 Recomputed reservoir budgets",
+		zap.String("tenant", t.name),
+		zap.Int("arrivals", total),
+		zap.Int("quota", totalQuota),
+		zap.Int("types", len(budgets)))
+}
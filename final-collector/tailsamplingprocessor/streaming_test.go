@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+func TestStreamingReleaseSeedsBudgets(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Sampler = samplerStreaming
+	cfg.WriteTraceState = true
+	cfg.SampleRate = 0.5
+	sink := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t, cfg, sink)
+
+	for i := byte(1); i <= 20; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), testTrace(i, "GET /a", 10*time.Millisecond, "")))
+	}
+	tn := tsp.tenantFor(ptrace.NewTraces())
+	require.False(t, tn.reservoir.Budgeted())
+
+	// 第一个释放周期没有预算，每个类型只保留 defaultBudget 条，写入的概率是它的包含概率
+	tsp.recomputeBudgets(tn)
+	tsp.releaseReservoirs(tn)
+	assert.True(t, tn.reservoir.Budgeted())
+	states := exportedThresholds(sink)
+	require.Len(t, states, 1)
+	for _, state := range states {
+		assert.NotEqual(t, "ot=th:0", state)
+	}
+
+	// 预算在第一次释放前按 sample_rate 计算，下一个释放周期生效
+	sink.Reset()
+	for i := byte(21); i <= 40; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), testTrace(i, "GET /a", 10*time.Millisecond, "")))
+	}
+	tsp.releaseReservoirs(tn)
+	assert.Greater(t, sink.SpanCount(), 1)
+}
+
+func TestStreamingBypassesErrorClassCap(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Sampler = samplerStreaming
+	cfg.ErrorClasses.Enabled = true
+	cfg.ErrorClasses.MaxPerClass = 1
+	sink := new(consumertest.TracesSink)
+	tsp := newTestProcessor(t, cfg, sink)
+
+	// 同一错误类别的异常追踪到达即导出，不受 max_per_class 限制
+	for i := byte(1); i <= 3; i++ {
+		td := testTrace(i, "GET /error", 10*time.Millisecond, "")
+		td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Status().SetCode(ptrace.StatusCodeError)
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), td))
+	}
+	assert.Equal(t, 3, sink.SpanCount())
+	tn := tsp.tenantFor(ptrace.NewTraces())
+	assert.Empty(t, tn.reservoir.Release())
+}
+
+func TestStreamingThresholdsMatchProbability(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Sampler = samplerStreaming
+	cfg.WriteTraceState = true
+	tsp := newTestProcessor(t, cfg, nil)
+	tn := tsp.tenantFor(ptrace.NewTraces())
+
+	for i := byte(1); i <= 10; i++ {
+		require.NoError(t, tsp.ConsumeTraces(context.Background(), testTrace(i, "GET /a", time.Duration(i)*time.Millisecond, "")))
+	}
+	released := tn.reservoir.Release()
+	require.Len(t, released, 1)
+	p := released[0].Probability
+	assert.Greater(t, p, 0.0)
+	assert.Less(t, p, 1.0)
+
+	tsp.markSampled(released[0].Trace, p)
+	span := released[0].Trace.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "ot=th:"+tracepicker.ThresholdFromProbability(p), span.TraceState().AsRaw())
+}
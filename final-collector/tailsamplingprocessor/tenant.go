@@ -0,0 +1,163 @@
+// file: processor/tailsamplingprocessor/tenant.go
+
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+// tenant 是一个租户独立的采样状态：缓冲区、延迟历史、标签下标、跨批次累积数据、
+// 遗传算法的精英、类型计数和蓄水池。
+// 未配置 tenancy.attribute 时只有一个名称为空的租户，行为与不分租户时一致。
+// 标签归一化和错误分类在租户之间共享。
+type tenant struct {
+	name         string
+	override     TenantOverrideCfg
+	buffer       *tracepicker.SharedBuffer
+	histPool     *tracepicker.HistPool
+	labels       *tracepicker.LabelRegistry
+	cumulative   *tracepicker.CumulativeSketch
+	elites       *tracepicker.EliteArchive
+	encoder      *tracepicker.BFSEncoder
+	typeRegistry *tracepicker.TypeRegistry
+	reservoir    *tracepicker.ReservoirSampler
+	pathCounter  *tracepicker.PathCounter
+	redTypes     *redTypeSet
+}
+
+// newTenant 按当前运行时参数创建一个租户，调用方需持有 tenantsMu。
+func (tsp *tailSamplingSpanProcessor) newTenant(name string) *tenant {
+	cfg := tsp.config
+	params := tsp.currentParams()
+
+	histPool := tracepicker.NewHistPool(cfg.PoolHeight)
+	encoder := tracepicker.NewBFSEncoder(histPool, tsp.labels)
+	encoder.SetAbnormalSigma(params.AbnormalSigma)
+
+	t := &tenant{
+		name:     name,
+		override: cfg.Tenancy.Overrides[name],
+		histPool: histPool,
+		labels:   tracepicker.NewLabelRegistry(cfg.LatencyFeatures.MaxLabels),
+		encoder:  encoder,
+	}
+	// 开启状态共享时本地计数与远端摘要使用相同的半衰期，发布的是衰减后的近期计数
+	var pathHalfLife time.Duration
+	if cfg.StateSync.Enabled {
+		pathHalfLife = cfg.StateSync.DecayHalfLife
+	}
+	t.pathCounter = tracepicker.NewPathCounter(pathHalfLife)
+	t.buffer = tracepicker.NewSharedBuffer(t.bufferLimits(params, cfg), tracepicker.PayloadStoreConfig{
+		MemoryLimit: cfg.PayloadStore.MemoryLimit,
+		Directory:   cfg.PayloadStore.Directory,
+	})
+	if cfg.Objective.CumulativeWeight > 0 {
+		t.cumulative = tracepicker.NewCumulativeSketch(cfg.Objective.CumulativeHalfLife)
+	}
+	if cfg.Optimizer.EliteArchiveSize > 0 {
+		t.elites = tracepicker.NewEliteArchive(cfg.Optimizer.EliteArchiveSize)
+	}
+	if cfg.NovelTypes.Enabled {
+		t.typeRegistry = tracepicker.NewTypeRegistry(cfg.NovelTypes.KeepFirst, cfg.NovelTypes.RarityThreshold, cfg.NovelTypes.MaxTypes)
+	}
+	if cfg.RedMetrics.IncludeTypeID {
+		t.redTypes = newRedTypeSet(cfg.RedMetrics.MaxTraceTypes)
+	}
+	if cfg.Sampler == samplerStreaming {
+		t.reservoir = tracepicker.NewReservoirSampler(1, time.Now().UnixNano())
+	}
+	return t
+}
+
+// sampleRate 返回租户的采样率，租户没有覆盖时使用运行时参数。
+func (t *tenant) sampleRate(params runtimeParams) float64 {
+	if t.override.SampleRate > 0 {
+		return t.override.SampleRate
+	}
+	return params.SampleRate
+}
+
+// bufferLimits 返回租户缓冲区的上限，租户没有覆盖 buffer_size 时使用运行时参数。
+func (t *tenant) bufferLimits(params runtimeParams, cfg Config) tracepicker.BufferLimits {
+	traces := params.BufferSize
+	if t.override.BufferSize > 0 {
+		traces = t.override.BufferSize
+	}
+	return tracepicker.BufferLimits{
+		Traces: traces,
+		Spans:  cfg.MaxBufferedSpans,
+		Bytes:  cfg.MaxBufferedBytes,
+	}
+}
+
+// metricAttributes 返回租户附加在指标上的属性，不分租户时为空。
+func (t *tenant) metricAttributes() []attribute.KeyValue {
+	if t.name == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("tenant", t.name)}
+}
+
+// tenantFor 返回追踪所属的租户，租户不存在时创建。
+// 租户数达到 MaxTenants 后，新租户的追踪归入默认租户。
+func (tsp *tailSamplingSpanProcessor) tenantFor(td ptrace.Traces) *tenant {
+	name := tsp.tenantName(td)
+
+	tsp.tenantsMu.RLock()
+	t, ok := tsp.tenants[name]
+	tsp.tenantsMu.RUnlock()
+	if ok {
+		return t
+	}
+
+	tsp.tenantsMu.Lock()
+	defer tsp.tenantsMu.Unlock()
+	if t, ok = tsp.tenants[name]; ok {
+		return t
+	}
+	if limit := tsp.config.Tenancy.MaxTenants; limit > 0 && len(tsp.tenants) >= limit {
+		name = tsp.config.Tenancy.DefaultTenant
+		if t, ok = tsp.tenants[name]; ok {
+			return t
+		}
+	}
+	t = tsp.newTenant(name)
+	tsp.tenants[name] = t
+	return t
+}
+
+// tenantName 返回追踪第一个带有 tenancy.attribute 的资源上的属性值，都没有时返回默认租户。
+func (tsp *tailSamplingSpanProcessor) tenantName(td ptrace.Traces) string {
+	cfg := tsp.config.Tenancy
+	if cfg.Attribute == "" {
+		return ""
+	}
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		if val, ok := rs.At(i).Resource().Attributes().Get(cfg.Attribute); ok && val.AsString() != "" {
+			return val.AsString()
+		}
+	}
+	return cfg.DefaultTenant
+}
+
+// allTenants 返回按名称排序的所有租户。
+func (tsp *tailSamplingSpanProcessor) allTenants() []*tenant {
+	tsp.tenantsMu.RLock()
+	defer tsp.tenantsMu.RUnlock()
+
+	tenants := make([]*tenant, 0, len(tsp.tenants))
+	for _, t := range tsp.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].name < tenants[j].name })
+	return tenants
+}
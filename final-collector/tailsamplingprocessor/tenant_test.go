@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package tailsamplingprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/samplingCollector/tailsamplingprocessor/internal/tracepicker"
+)
+
+// tenantTrace 返回一条资源上各带一组属性的追踪，nil 表示资源没有属性。
+func tenantTrace(resources ...map[string]string) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, attrs := range resources {
+		rs := td.ResourceSpans().AppendEmpty()
+		for k, v := range attrs {
+			rs.Resource().Attributes().PutStr(k, v)
+		}
+	}
+	return td
+}
+
+func TestTenantFor(t *testing.T) {
+	tests := []struct {
+		name       string
+		attribute  string
+		maxTenants int
+		traces     []ptrace.Traces
+		want       []string
+	}{
+		{
+			name:   "no tenancy",
+			traces: []ptrace.Traces{tenantTrace(map[string]string{"service.namespace": "shop"})},
+			want:   []string{""},
+		},
+		{
+			name:      "first resource with attribute",
+			attribute: "service.namespace",
+			traces: []ptrace.Traces{
+				tenantTrace(nil, map[string]string{"service.namespace": "shop"}, map[string]string{"service.namespace": "billing"}),
+				tenantTrace(map[string]string{"service.namespace": "billing"}),
+			},
+			want: []string{"shop", "billing"},
+		},
+		{
+			name:      "missing attribute uses default tenant",
+			attribute: "service.namespace",
+			traces: []ptrace.Traces{
+				tenantTrace(map[string]string{"service.name": "cart"}),
+				tenantTrace(map[string]string{"service.namespace": ""}),
+			},
+			want: []string{"default", "default"},
+		},
+		{
+			// 默认租户在创建处理器时就存在，也计入上限
+			name:       "max tenants overflow into default tenant",
+			attribute:  "service.namespace",
+			maxTenants: 3,
+			traces: []ptrace.Traces{
+				tenantTrace(map[string]string{"service.namespace": "shop"}),
+				tenantTrace(map[string]string{"service.namespace": "billing"}),
+				tenantTrace(map[string]string{"service.namespace": "search"}),
+				tenantTrace(map[string]string{"service.namespace": "shop"}),
+			},
+			want: []string{"shop", "billing", "default", "shop"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Tenancy.Attribute = tt.attribute
+			cfg.Tenancy.DefaultTenant = "default"
+			cfg.Tenancy.MaxTenants = tt.maxTenants
+			tsp := newTestProcessor(t, cfg, nil)
+
+			var got []string
+			for _, td := range tt.traces {
+				got = append(got, tsp.tenantFor(td).name)
+			}
+			assert.Equal(t, tt.want, got)
+			// 同名租户只创建一次
+			for _, td := range tt.traces {
+				assert.Same(t, tsp.tenantFor(td), tsp.tenantFor(td))
+			}
+		})
+	}
+}
+
+func TestTenantOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		override   TenantOverrideCfg
+		wantRate   float64
+		wantTraces uint64
+	}{
+		{name: "global values", wantRate: 0.1, wantTraces: 4000},
+		{name: "sample rate override", override: TenantOverrideCfg{SampleRate: 0.5}, wantRate: 0.5, wantTraces: 4000},
+		{name: "buffer size override", override: TenantOverrideCfg{BufferSize: 100}, wantRate: 0.1, wantTraces: 100},
+		{name: "both overrides", override: TenantOverrideCfg{SampleRate: 0.2, BufferSize: 10}, wantRate: 0.2, wantTraces: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.Tenancy.Attribute = "service.namespace"
+			cfg.Tenancy.Overrides = map[string]TenantOverrideCfg{"shop": tt.override}
+			cfg.MaxBufferedSpans = 1000
+			tsp := newTestProcessor(t, cfg, nil)
+
+			shop := tsp.tenantFor(tenantTrace(map[string]string{"service.namespace": "shop"}))
+			params := tsp.currentParams()
+			assert.InDelta(t, tt.wantRate, shop.sampleRate(params), 1e-9)
+			assert.Equal(t, tracepicker.BufferLimits{Traces: tt.wantTraces, Spans: 1000}, shop.bufferLimits(params, cfg))
+			assert.Equal(t, tt.wantTraces, shop.buffer.Limits().Traces)
+
+			// 其他租户使用全局值
+			other := tsp.tenantFor(tenantTrace(map[string]string{"service.namespace": "billing"}))
+			assert.InDelta(t, 0.1, other.sampleRate(params), 1e-9)
+			assert.Equal(t, uint64(4000), other.bufferLimits(params, cfg).Traces)
+		})
+	}
+}